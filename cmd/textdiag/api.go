@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxRequestBytes is the largest sequence diagram accepted by the API
+const maxRequestBytes = 1 << 20
+
+// apiMediaTypes are the response media types of the render API and their formats
+var apiMediaTypes = []struct {
+	mediaType string
+	format    string
+}{
+	{"text/plain", "text"},
+	{"image/svg+xml", "svg"},
+	{"application/json", "json"},
+}
+
+// apiRenderRequest is the JSON request body of the render and save APIs
+type apiRenderRequest struct {
+	Source string `json:"source"`
+}
+
+// apiError is a JSON error response, parse errors have the line of the error
+type apiError struct {
+	Errors []apiErrorDetail `json:"errors"`
+}
+
+type apiErrorDetail struct {
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+// renderAPI handles POST /api/v1/render. The request body is the sequence
+// diagram as text/plain or as JSON {"source": "..."}, and the response is the
+// text diagram, the SVG or the JSON model depending on the Accept header.
+func renderAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeAPIError(w, http.StatusMethodNotAllowed, "Only POST is allowed.")
+		return
+	}
+	mediaType, format, ok := negotiate(r.Header.Get("Accept"))
+	if !ok {
+		writeAPIError(w, http.StatusNotAcceptable, "Supported response types are text/plain, image/svg+xml and application/json.")
+		return
+	}
+
+	source, ok := readSource(w, r)
+	if !ok {
+		return
+	}
+	sd, err := parseObserved("render", source)
+	if err != nil {
+		logJSON(r.Context(), "info", "parse error", "error", err)
+		writeJSON(w, http.StatusBadRequest, parseErrors(err))
+		return
+	}
+	w.Header().Set("Content-Type", mediaType+"; charset=utf-8")
+	if err := timeRender(format, func() error { return formats[format](w, sd) }); err != nil {
+		logJSON(r.Context(), "error", "writing response failed", "format", format, "error", err)
+	}
+}
+
+// readSource reads the sequence diagram of a request body sent as text/plain
+// or as JSON {"source": "..."}, or writes the error response
+func readSource(w http.ResponseWriter, r *http.Request) (string, bool) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	if err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			writeAPIError(w, http.StatusRequestEntityTooLarge, "Request body is larger than "+strconv.Itoa(maxRequestBytes)+" bytes.")
+			return "", false
+		}
+		writeAPIError(w, http.StatusBadRequest, "Reading request body: "+err.Error())
+		return "", false
+	}
+	contentType := "text/plain"
+	if header := r.Header.Get("Content-Type"); header != "" {
+		if contentType, _, err = mime.ParseMediaType(header); err != nil {
+			writeAPIError(w, http.StatusUnsupportedMediaType, "Invalid Content-Type.")
+			return "", false
+		}
+	}
+	switch contentType {
+	case "text/plain":
+		return string(body), true
+	case "application/json":
+		var request apiRenderRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return "", false
+		}
+		return request.Source, true
+	}
+	writeAPIError(w, http.StatusUnsupportedMediaType, "Supported request types are text/plain and application/json.")
+	return "", false
+}
+
+// negotiate picks the response media type with the highest quality in the
+// Accept header, the quality of a type is set by its most specific media range.
+// No header accepts text/plain.
+func negotiate(accept string) (string, string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return apiMediaTypes[0].mediaType, apiMediaTypes[0].format, true
+	}
+	qualities := make([]float64, len(apiMediaTypes))
+	specificities := make([]int, len(apiMediaTypes))
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		for i, t := range apiMediaTypes {
+			specificity := 0
+			switch {
+			case mediaRange == t.mediaType:
+				specificity = 3
+			case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(t.mediaType, strings.TrimSuffix(mediaRange, "*")):
+				specificity = 2
+			case mediaRange == "*/*":
+				specificity = 1
+			}
+			if specificity > specificities[i] {
+				specificities[i], qualities[i] = specificity, quality
+			}
+		}
+	}
+	// ties keep the order of apiMediaTypes
+	best := -1
+	for i, quality := range qualities {
+		if quality > 0 && (best < 0 || quality > qualities[best]) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return "", "", false
+	}
+	return apiMediaTypes[best].mediaType, apiMediaTypes[best].format, true
+}
+
+// parseErrors splits "Line N: message" errors into their line and message
+func parseErrors(err error) apiError {
+	var result apiError
+	for _, line := range strings.Split(err.Error(), "\n") {
+		detail := apiErrorDetail{Message: line}
+		if match := lineErrorPattern.FindStringSubmatch(line); match != nil {
+			detail.Line, _ = strconv.Atoi(match[1])
+			detail.Message = match[2]
+		}
+		result.Errors = append(result.Errors, detail)
+	}
+	return result
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, apiError{[]apiErrorDetail{{Message: message}}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		logJSON(context.Background(), "error", "marshalling response failed", "error", err)
+		http.Error(w, "failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(append(b, '\n'))
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRenderAPI(t *testing.T) {
+	diagram := "┌───┐     ┌───┐\n│ a │     │ b │\n└───┘     └───┘\n  │  ┌───┐  │\n   ──┤ x ├─▶\n  │  └───┘  │\n┌───┐     ┌───┐\n│ a │     │ b │\n└───┘     └───┘\n"
+	tests := []struct {
+		method      string
+		contentType string
+		accept      string
+		body        string
+		status      int
+		wantType    string
+		want        string
+	}{
+		{"POST", "", "", "a->b:x\n", 200, "text/plain; charset=utf-8", diagram},
+		{"POST", "text/plain; charset=utf-8", "text/plain", "a->b:x", 200, "text/plain; charset=utf-8", diagram},
+		{"POST", "application/json", "text/*", `{"source": "a->b:x"}`, 200, "text/plain; charset=utf-8", diagram},
+		{"POST", "text/plain", "image/svg+xml", "a->b:x", 200, "image/svg+xml; charset=utf-8", "<svg"},
+		{"POST", "text/plain", "text/plain;q=0.5, application/json", "a->b:x", 200, "application/json; charset=utf-8", `"participants"`},
+		{"POST", "text/plain", "", "a->b:x\nbad", 400, "application/json; charset=utf-8", `{"errors":[{"line":2,"message":"Syntax error."}]}` + "\n"},
+		{"POST", "application/json", "", `{"source": 1}`, 400, "application/json; charset=utf-8", `"Invalid JSON: `},
+		{"POST", "text/plain", "text/html", "a->b:x", 406, "application/json; charset=utf-8", "Supported response types"},
+		{"POST", "application/xml", "", "<a/>", 415, "application/json; charset=utf-8", "Supported request types"},
+		{"POST", "text/plain", "", strings.Repeat("a->b:x\n", maxRequestBytes/7+1), 413, "application/json; charset=utf-8", "Request body is larger"},
+		{"GET", "", "", "", 405, "application/json; charset=utf-8", `{"errors":[{"message":"Only POST is allowed."}]}` + "\n"},
+	}
+	mux := newWebMux(newMemoryStore(1<<20), nil)
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, "/api/v1/render", strings.NewReader(test.body))
+		if test.contentType != "" {
+			r.Header.Set("Content-Type", test.contentType)
+		}
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		got := w.Body.String()
+		if w.Code != test.status || w.Header().Get("Content-Type") != test.wantType || !strings.Contains(got, test.want) {
+			t.Errorf("TestRenderAPI => %s %s (Accept %q) got %d %s:\n%.200s\nwant %d %s:\n%s",
+				test.method, test.contentType, test.accept, w.Code, w.Header().Get("Content-Type"), got, test.status, test.wantType, test.want)
+		}
+		if test.status == 405 && w.Header().Get("Allow") != "POST" {
+			t.Errorf("TestRenderAPI => %s got Allow %q, want POST", test.method, w.Header().Get("Allow"))
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+		ok     bool
+	}{
+		{"", "text", true},
+		{"*/*", "text", true},
+		{"image/*", "svg", true},
+		{"application/json, text/plain", "text", true},
+		{"application/json;q=1, text/plain;q=0.9", "json", true},
+		{"text/plain;q=0, */*;q=0.1", "svg", true},
+		{"text/html, image/png", "", false},
+	}
+	for _, test := range tests {
+		_, got, ok := negotiate(test.accept)
+		if got != test.want || ok != test.ok {
+			t.Errorf("TestNegotiate => %q got %q %v, want %q %v", test.accept, got, ok, test.want, test.ok)
+		}
+	}
+}
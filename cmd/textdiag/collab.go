@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Laugusti/sequencediagram/textdiagram"
+)
+
+// collabPrefix is the path of the WebSocket of a room, /ws/{room}
+const collabPrefix = "/ws/"
+
+const (
+	// collabHistory is the number of versions of a room kept to merge edits
+	collabHistory = 100
+	// collabHistoryBytes bounds the size of the versions kept, older versions
+	// are dropped first
+	collabHistoryBytes = 8 << 20
+	// collabMaxRooms and collabMaxClients bound the rooms of the server and
+	// the clients of a room
+	collabMaxRooms   = 1000
+	collabMaxClients = 50
+	// collabSendQueue is the number of states queued for a client before it is
+	// disconnected as too slow
+	collabSendQueue = 16
+)
+
+var roomPattern = regexp.MustCompile("^[A-Za-z0-9_-]{1,64}$")
+
+// collabEdit is the full text of a client after an edit. Base is the version
+// the edit started from and Seq numbers the edits of the client.
+type collabEdit struct {
+	Seq    int    `json:"seq"`
+	Base   int    `json:"base"`
+	Source string `json:"source"`
+}
+
+// collabState is sent to every client after a change. Diagram is the last
+// rendering without errors, Errors are the parse errors of Source, and Ack is
+// the last edit of the receiving client that is included.
+type collabState struct {
+	Version int              `json:"version"`
+	Source  string           `json:"source"`
+	Diagram string           `json:"diagram"`
+	Errors  []apiErrorDetail `json:"errors,omitempty"`
+	Clients int              `json:"clients"`
+	Ack     int              `json:"ack"`
+}
+
+// collabHub has the rooms with at least one client. Pages of other origins
+// than the server's own can only join if they are in origins.
+type collabHub struct {
+	origins    []string
+	maxRooms   int
+	maxClients int
+	mu         sync.Mutex
+	rooms      map[string]*collabRoom
+}
+
+// collabRoom keeps the versions from oldest to version in history, with
+// historyBytes the length of their text
+type collabRoom struct {
+	mu           sync.Mutex
+	clients      map[*collabClient]bool
+	version      int
+	oldest       int
+	history      map[int]string
+	historyBytes int
+	source       string
+	diagram      string
+	errors       []apiErrorDetail
+}
+
+type collabClient struct {
+	conn *wsConn
+	send chan []byte
+	ack  int
+}
+
+func newCollabHub(origins []string) *collabHub {
+	return &collabHub{
+		origins:    origins,
+		maxRooms:   collabMaxRooms,
+		maxClients: collabMaxClients,
+		rooms:      make(map[string]*collabRoom),
+	}
+}
+
+// collab joins the WebSocket client to the room of the path. Edits are merged
+// into the room's text and the new state is sent to every client. When the
+// server has no room left for the client, it is refused before the upgrade.
+func (h *collabHub) collab(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, collabPrefix)
+	if !roomPattern.MatchString(name) {
+		http.NotFound(w, r)
+		return
+	}
+	if !h.canJoin(name) {
+		http.Error(w, "Too many collaborators, try again later.", http.StatusServiceUnavailable)
+		return
+	}
+	conn, err := upgradeWebSocket(w, r, h.origins)
+	if err != nil {
+		logJSON(r.Context(), "info", "websocket upgrade failed", "room", name, "error", err)
+		return
+	}
+	client := &collabClient{conn: conn, send: make(chan []byte, collabSendQueue)}
+	room := h.join(name, client)
+	if room == nil {
+		// the room filled up during the upgrade
+		conn.Close()
+		return
+	}
+	go client.writeStates()
+	defer h.leave(name, room, client)
+
+	for {
+		message, err := conn.ReadMessage()
+		if err != nil {
+			if err != io.EOF {
+				logJSON(r.Context(), "info", "websocket closed", "room", name, "error", err)
+			}
+			return
+		}
+		var edit collabEdit
+		if err := json.Unmarshal([]byte(message), &edit); err != nil {
+			logJSON(r.Context(), "info", "invalid edit", "room", name, "error", err)
+			continue
+		}
+		room.edit(client, edit)
+	}
+}
+
+// canJoin reports whether a client can join the room without going over the
+// room and client limits
+func (h *collabHub) canJoin(name string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	room, ok := h.rooms[name]
+	if !ok {
+		return len(h.rooms) < h.maxRooms
+	}
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	return len(room.clients) < h.maxClients
+}
+
+// join adds the client to the room, it returns nil if there is no room for it
+func (h *collabHub) join(name string, client *collabClient) *collabRoom {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	room, ok := h.rooms[name]
+	if !ok {
+		if len(h.rooms) >= h.maxRooms {
+			return nil
+		}
+		room = &collabRoom{clients: make(map[*collabClient]bool), history: map[int]string{0: ""}}
+		h.rooms[name] = room
+	}
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	if len(room.clients) >= h.maxClients {
+		return nil
+	}
+	room.clients[client] = true
+	room.broadcast()
+	return room
+}
+
+// leave removes the client, and the room once it is empty
+func (h *collabHub) leave(name string, room *collabRoom, client *collabClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	room.remove(client)
+	if len(room.clients) == 0 {
+		if h.rooms[name] == room {
+			delete(h.rooms, name)
+		}
+		return
+	}
+	room.broadcast()
+}
+
+// edit merges the edit with the changes made since its base version. Edits
+// from a version that is no longer kept replace the text.
+func (room *collabRoom) edit(client *collabClient, edit collabEdit) {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	if !room.clients[client] {
+		return
+	}
+	client.ack = edit.Seq
+	source := edit.Source
+	if base, ok := room.history[edit.Base]; ok && edit.Base != room.version {
+		source = mergeLines(base, room.source, edit.Source)
+	}
+	if source != room.source {
+		room.version++
+		room.source = source
+		room.history[room.version] = source
+		room.historyBytes += len(source)
+		room.trimHistory()
+		room.render()
+	}
+	room.broadcast()
+}
+
+// trimHistory drops the oldest versions until at most collabHistory versions
+// and collabHistoryBytes bytes are kept, the current version is always kept
+func (room *collabRoom) trimHistory() {
+	for room.oldest < room.version && (room.version-room.oldest >= collabHistory || room.historyBytes > collabHistoryBytes) {
+		room.historyBytes -= len(room.history[room.oldest])
+		delete(room.history, room.oldest)
+		room.oldest++
+	}
+}
+
+// render keeps the last diagram without errors
+func (room *collabRoom) render() {
+	if strings.TrimSpace(room.source) == "" {
+		room.diagram, room.errors = "", nil
+		return
+	}
+	sd, err := parseObserved("collab", room.source)
+	if err != nil {
+		room.errors = parseErrors(err).Errors
+		return
+	}
+	room.errors = nil
+	var b bytes.Buffer
+	timeRender("text", func() error {
+		_, err := io.Copy(&b, textdiagram.Encode(sd))
+		return err
+	})
+	room.diagram = b.String()
+}
+
+// broadcast queues the state for every client, clients that are too slow to
+// keep up are disconnected
+func (room *collabRoom) broadcast() {
+	state := collabState{room.version, room.source, room.diagram, room.errors, len(room.clients), 0}
+	for client := range room.clients {
+		state.Ack = client.ack
+		b, err := json.Marshal(state)
+		if err != nil {
+			logJSON(context.Background(), "error", "marshalling room state failed", "error", err)
+			return
+		}
+		select {
+		case client.send <- b:
+		default:
+			room.remove(client)
+		}
+	}
+}
+
+func (room *collabRoom) remove(client *collabClient) {
+	if room.clients[client] {
+		delete(room.clients, client)
+		close(client.send)
+		client.conn.Close()
+	}
+}
+
+// writeStates sends the queued states until the client is removed
+func (client *collabClient) writeStates() {
+	for b := range client.send {
+		if err := client.conn.WriteMessage(string(b)); err != nil {
+			client.conn.Close()
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wsTestClient is the client side of a WebSocket connection
+type wsTestClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dialWebSocket(t *testing.T, server *httptest.Server, path string) *wsTestClient {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	conn.Write([]byte("GET " + path + " HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\nSec-WebSocket-Version: 13\r\n\r\n"))
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the accept key of the RFC 6455 example
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("dialWebSocket => got %s, accept %q", resp.Status, resp.Header.Get("Sec-WebSocket-Accept"))
+	}
+	return &wsTestClient{t, conn, r}
+}
+
+func (c *wsTestClient) write(opcode byte, payload string) {
+	if err := writeFrame(c.conn, opcode, []byte(payload), true); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *wsTestClient) read() (byte, string) {
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, opcode, payload, err := readFrame(c.r, false, maxRequestBytes)
+	if err != nil {
+		c.t.Fatalf("reading frame: %v", err)
+	}
+	return opcode, string(payload)
+}
+
+func (c *wsTestClient) edit(seq, base int, source string) {
+	b, _ := json.Marshal(collabEdit{seq, base, source})
+	c.write(wsText, string(b))
+}
+
+// state reads states until one satisfies done
+func (c *wsTestClient) state(done func(collabState) bool) collabState {
+	for {
+		opcode, payload := c.read()
+		if opcode != wsText {
+			c.t.Fatalf("got opcode %d %q, want a state", opcode, payload)
+		}
+		var state collabState
+		if err := json.Unmarshal([]byte(payload), &state); err != nil {
+			c.t.Fatal(err)
+		}
+		if done(state) {
+			return state
+		}
+	}
+}
+
+func TestWebSocketFrames(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgradeWebSocket(w, r, nil)
+		if err != nil {
+			return
+		}
+		// echo
+		for {
+			message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(message)
+		}
+	}))
+	defer server.Close()
+
+	c := dialWebSocket(t, server, "/")
+	c.write(wsPing, "p")
+	if opcode, payload := c.read(); opcode != wsPong || payload != "p" {
+		t.Errorf("TestWebSocketFrames => ping got %d %q, want pong", opcode, payload)
+	}
+	// a fragmented message with a long second part
+	long := strings.Repeat("x", 70000)
+	c.conn.Write(maskedFrame(wsText, false, "a"))
+	c.write(wsContinuation, long)
+	if opcode, payload := c.read(); opcode != wsText || payload != "a"+long {
+		t.Errorf("TestWebSocketFrames => fragmented message got %d with %d bytes, want %d bytes", opcode, len(payload), len(long)+1)
+	}
+	c.write(wsBinary, "b")
+	if opcode, payload := c.read(); opcode != wsClose || binary.BigEndian.Uint16([]byte(payload)) != wsCloseUnsupported {
+		t.Errorf("TestWebSocketFrames => binary message got %d %q, want close %d", opcode, payload, wsCloseUnsupported)
+	}
+
+	// client frames must be masked
+	c = dialWebSocket(t, server, "/")
+	writeFrame(c.conn, wsText, []byte("x"), false)
+	if opcode, payload := c.read(); opcode != wsClose || binary.BigEndian.Uint16([]byte(payload)) != wsCloseProtocolError {
+		t.Errorf("TestWebSocketFrames => unmasked frame got %d %q, want close %d", opcode, payload, wsCloseProtocolError)
+	}
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUpgradeRequired {
+		t.Errorf("TestWebSocketFrames => plain GET got %s, want %d", resp.Status, http.StatusUpgradeRequired)
+	}
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	tests := []struct {
+		origin string
+		want   int
+	}{
+		// the response recorder cannot be hijacked, so allowed handshakes fail later
+		{"", http.StatusInternalServerError},
+		{"http://textdiag.internal:8080", http.StatusInternalServerError},
+		{"https://wiki.example.com", http.StatusInternalServerError},
+		{"HTTPS://WIKI.example.com", http.StatusInternalServerError},
+		{"https://evil.example", http.StatusForbidden},
+		{"http://textdiag.internal", http.StatusForbidden},
+		{"null", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "http://textdiag.internal:8080/ws/design", nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		if test.origin != "" {
+			req.Header.Set("Origin", test.origin)
+		}
+		w := httptest.NewRecorder()
+		upgradeWebSocket(w, req, []string{"https://wiki.example.com/"})
+		if w.Code != test.want {
+			t.Errorf("TestWebSocketOrigin => origin %q got %d, want %d", test.origin, w.Code, test.want)
+		}
+	}
+}
+
+// maskedFrame is a client frame that may not be final
+func maskedFrame(opcode byte, fin bool, payload string) []byte {
+	var b strings.Builder
+	writeFrame(&b, opcode, []byte(payload), true)
+	frame := []byte(b.String())
+	if !fin {
+		frame[0] &^= 0x80
+	}
+	return frame
+}
+
+func TestCollab(t *testing.T) {
+	server := httptest.NewServer(newWebMux(newMemoryStore(1<<20), nil))
+	defer server.Close()
+
+	alice := dialWebSocket(t, server, "/ws/design")
+	alice.state(func(s collabState) bool { return s.Clients == 1 })
+	bob := dialWebSocket(t, server, "/ws/design")
+	bob.state(func(s collabState) bool { return s.Clients == 2 })
+
+	alice.edit(1, 0, "a->b:x\nb-->a:y")
+	state := bob.state(func(s collabState) bool { return s.Version == 1 })
+	if state.Source != "a->b:x\nb-->a:y" || !strings.Contains(state.Diagram, "┤ x ├") || state.Errors != nil || state.Ack != 0 {
+		t.Errorf("TestCollab => bob got %+v after alice's edit", state)
+	}
+	if state := alice.state(func(s collabState) bool { return s.Version == 1 }); state.Ack != 1 {
+		t.Errorf("TestCollab => alice got ack %d, want 1", state.Ack)
+	}
+
+	// concurrent edits of version 1 are merged line-wise
+	alice.edit(2, 1, "a->b:hello\nb-->a:y")
+	bob.edit(1, 1, "a->b:x\nb-->a:bye")
+	want := "a->b:hello\nb-->a:bye"
+	for _, c := range []*wsTestClient{alice, bob} {
+		state := c.state(func(s collabState) bool { return s.Version == 3 })
+		if state.Source != want || !strings.Contains(state.Diagram, "┤ hello ├") || !strings.Contains(state.Diagram, "┤ bye ├") {
+			t.Errorf("TestCollab => got %+v after concurrent edits, want source %q", state, want)
+		}
+	}
+
+	// the last diagram stays while the text has errors
+	bob.edit(2, 3, want+"\nbad")
+	state = alice.state(func(s collabState) bool { return s.Version == 4 })
+	if !strings.Contains(state.Diagram, "┤ bye ├") || len(state.Errors) != 1 || state.Errors[0] != (apiErrorDetail{3, "Syntax error."}) {
+		t.Errorf("TestCollab => got %+v after an invalid edit", state)
+	}
+
+	// other rooms are separate
+	carol := dialWebSocket(t, server, "/ws/other")
+	if state := carol.state(func(collabState) bool { return true }); state.Source != "" || state.Clients != 1 {
+		t.Errorf("TestCollab => new room got %+v", state)
+	}
+
+	// queued states are read until the close frame
+	bob.write(wsClose, "")
+	for opcode, _ := bob.read(); opcode != wsClose; opcode, _ = bob.read() {
+	}
+	if state := alice.state(func(s collabState) bool { return s.Clients == 1 }); state.Version != 4 {
+		t.Errorf("TestCollab => after bob left got %+v", state)
+	}
+}
+
+func TestCollabLimits(t *testing.T) {
+	hub := newCollabHub(nil)
+	hub.maxRooms, hub.maxClients = 1, 1
+	server := httptest.NewServer(http.HandlerFunc(hub.collab))
+	defer server.Close()
+
+	alice := dialWebSocket(t, server, "/ws/design")
+	alice.state(func(s collabState) bool { return s.Clients == 1 })
+	for _, path := range []string{"/ws/design", "/ws/other"} {
+		req, _ := http.NewRequest("GET", server.URL+path, nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("TestCollabLimits => %s got %s, want 503", path, resp.Status)
+		}
+	}
+}
+
+func TestCollabHistoryBytes(t *testing.T) {
+	room := &collabRoom{clients: make(map[*collabClient]bool), history: map[int]string{0: ""}}
+	client := &collabClient{send: make(chan []byte, collabSendQueue)}
+	room.clients[client] = true
+	// a single invalid line, so rendering stops at the first error
+	edit := strings.Repeat("x", collabHistoryBytes/4)
+	for i := 1; i <= 5; i++ {
+		room.edit(client, collabEdit{i, room.version, edit + strings.Repeat("y", i)})
+		<-client.send
+	}
+	if room.historyBytes > collabHistoryBytes || len(room.history) != 3 || room.oldest != 3 {
+		t.Errorf("TestCollabHistoryBytes => got %d versions from %d of %d bytes, want 3 from 3 of at most %d",
+			len(room.history), room.oldest, room.historyBytes, collabHistoryBytes)
+	}
+	if _, ok := room.history[room.version]; !ok {
+		t.Errorf("TestCollabHistoryBytes => the current version %d is not kept", room.version)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/Laugusti/sequencediagram"
+	"github.com/Laugusti/sequencediagram/textdiagram"
+)
+
+// patternList is a flag that can be repeated or given comma separated values
+type patternList []string
+
+func (p *patternList) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *patternList) Set(value string) error {
+	for _, pattern := range strings.Split(value, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			*p = append(*p, pattern)
+		}
+	}
+	return nil
+}
+
+// importHAR prints the text diagram of each HAR file
+func importHAR(args []string) {
+	var filter sequencediagram.HARFilter
+	fs := flag.NewFlagSet("import-har", flag.ExitOnError)
+	source := fs.Bool("source", false, "print the sequence diagram instead of the text diagram")
+	fs.Var((*patternList)(&filter.Hosts), "host", "only include requests to hosts matching the pattern (repeatable)")
+	fs.Var((*patternList)(&filter.ContentTypes), "type", "only include responses with a content type matching the pattern (repeatable)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s import-har [-source] [-host pattern] [-type pattern] file...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	for _, filename := range fs.Args() {
+		sd, err := sequencediagram.ReadHARFile(filename, filter)
+		if err != nil {
+			log.Fatalf("failed to import HAR %s: %v", filename, err)
+		}
+		if *source {
+			fmt.Println(sd)
+			continue
+		}
+		io.Copy(os.Stdout, textdiagram.Encode(sd))
+		fmt.Println()
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/Laugusti/sequencediagram/lint"
+)
+
+// lintConfigFile is read by lint when no -config flag is given, if it exists
+const lintConfigFile = ".textdiaglint.json"
+
+// lintCommand reports likely mistakes in sequence diagram files
+func lintCommand(args []string) {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	config := fs.String("config", "", "JSON file enabling or disabling rules (default "+lintConfigFile+" if it exists)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s lint [-config file] file...\n", os.Args[0])
+		fs.PrintDefaults()
+		fmt.Fprintf(fs.Output(), "Rules: %s\n", strings.Join(lint.RuleNames(), ", "))
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	cfg, err := loadLintConfig(*config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, name := range fs.Args() {
+		n, err := lintFile(os.Stdout, name, cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		if err != nil || n > 0 {
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// loadLintConfig reads the named config file, or lintConfigFile if name is
+// empty and the file exists
+func loadLintConfig(name string) (lint.Config, error) {
+	if name == "" {
+		if _, err := os.Stat(lintConfigFile); err != nil {
+			return lint.DefaultConfig(), nil
+		}
+		name = lintConfigFile
+	}
+	return lint.LoadConfig(name)
+}
+
+// lintFile writes the findings of the named file as "file:N: message (rule)"
+// and returns the number of findings
+func lintFile(w io.Writer, name string, cfg lint.Config) (int, error) {
+	text, err := ioutil.ReadFile(name)
+	if err != nil {
+		return 0, err
+	}
+	sd, err := parseSource(string(text))
+	if err != nil {
+		return 0, positionalError(name, err)
+	}
+	findings := lint.Lint(sd, cfg)
+	for _, f := range findings {
+		if _, err := fmt.Fprintf(w, "%s:%d: %s (%s)\n", name, f.Line, f.Message, f.Rule); err != nil {
+			return len(findings), err
+		}
+	}
+	return len(findings), nil
+}
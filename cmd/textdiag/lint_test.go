@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/Laugusti/sequencediagram/lint"
+)
+
+func TestLintFile(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		src   string
+		want  string
+		count int
+		err   string
+	}{
+		{"participant a\nparticipant b\na->b:x\nb-->a:y\n", "", 0, ""},
+		{"title x\ntitle y\r\na->b:x\n", "flow.sd:2: Title was already set on line 1, only the last title is used. (duplicate-title)\n" +
+			"flow.sd:3: Request from a to b has no response. (missing-response)\n", 2, ""},
+		{"a->b:x\nbad\n", "", 0, "flow.sd:2: Syntax error."},
+	}
+	for _, test := range tests {
+		name := filepath.Join(dir, "flow.sd")
+		if err := ioutil.WriteFile(name, []byte(test.src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		n, err := lintFile(&b, name, lint.DefaultConfig())
+		got := string(bytes.Replace(b.Bytes(), []byte(dir+string(filepath.Separator)), nil, -1))
+		if err != nil {
+			if got := string(bytes.Replace([]byte(err.Error()), []byte(dir+string(filepath.Separator)), nil, -1)); got != test.err {
+				t.Errorf("TestLintFile => input: %q, got error %q, want %q", test.src, got, test.err)
+			}
+			continue
+		}
+		if test.err != "" {
+			t.Errorf("TestLintFile => input: %q, got no error, want %q", test.src, test.err)
+		}
+		if got != test.want || n != test.count {
+			t.Errorf("TestLintFile => input: %q, got %d findings:\n%s\nwant %d:\n%s", test.src, n, got, test.count, test.want)
+		}
+	}
+}
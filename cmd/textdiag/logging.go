@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requestIDHeader carries the request id of a request and its response
+const requestIDHeader = "X-Request-ID"
+
+var requestIDPattern = regexp.MustCompile("^[A-Za-z0-9._-]{1,64}$")
+
+type contextKey int
+
+const requestIDKey contextKey = iota
+
+// the web server writes one JSON object per line to logOutput
+var (
+	logOutput io.Writer = os.Stderr
+	logMu     sync.Mutex
+)
+
+// requestID returns the id of the request of ctx, or ""
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// logJSON writes a log line with the time, level, message, request id of ctx
+// and the key value pairs in order
+func logJSON(ctx context.Context, level, msg string, keyvals ...interface{}) {
+	fields := []interface{}{"time", time.Now().UTC().Format(time.RFC3339Nano), "level", level, "msg", msg}
+	if id := requestID(ctx); id != "" {
+		fields = append(fields, "request_id", id)
+	}
+	fields = append(fields, keyvals...)
+
+	line := []byte{'{'}
+	for i := 0; i+1 < len(fields); i += 2 {
+		value := fields[i+1]
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		k, _ := json.Marshal(fields[i])
+		v, err := json.Marshal(value)
+		if err != nil {
+			v, _ = json.Marshal(err.Error())
+		}
+		if i > 0 {
+			line = append(line, ',')
+		}
+		line = append(append(append(line, k...), ':'), v...)
+	}
+	line = append(line, '}', '\n')
+
+	logMu.Lock()
+	defer logMu.Unlock()
+	logOutput.Write(line)
+}
+
+// newRequestID is a random id for requests without a valid X-Request-ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// observe gives each request an id, counts it by route and status, and logs it
+func observe(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get(requestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey, id))
+		_, route := mux.Handler(r)
+
+		rec := &statusRecorder{ResponseWriter: w}
+		mux.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		httpRequests.inc(route, strconv.Itoa(rec.status))
+		logJSON(r.Context(), "info", "request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"route", route,
+			"status", rec.status,
+			"bytes", rec.bytes,
+			"duration_ms", float64(time.Since(start).Microseconds())/1000)
+	})
+}
+
+// statusRecorder records the status and size of a response, a hijacked
+// connection is recorded as 101 Switching Protocols
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+func (rec *statusRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rec *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rec.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		rec.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// logWriter writes the errors of the http.Server as JSON log lines
+type logWriter struct{}
+
+func (logWriter) Write(p []byte) (int, error) {
+	logJSON(context.Background(), "error", strings.TrimSpace(string(p)))
+	return len(p), nil
+}
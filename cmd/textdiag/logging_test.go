@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestLogJSON(t *testing.T) {
+	var b bytes.Buffer
+	defer func(w io.Writer) { logOutput = w }(logOutput)
+	logOutput = &b
+
+	ctx := context.WithValue(context.Background(), requestIDKey, "req-1")
+	logJSON(ctx, "error", "saving failed", "id", "x", "error", errors.New("disk full"), "n", 2)
+	line := regexp.MustCompile(`"time":"[^"]*"`).ReplaceAllString(b.String(), `"time":"T"`)
+	want := `{"time":"T","level":"error","msg":"saving failed","request_id":"req-1","id":"x","error":"disk full","n":2}` + "\n"
+	if line != want {
+		t.Errorf("TestLogJSON => got %q, want %q", line, want)
+	}
+}
+
+func TestRequestLog(t *testing.T) {
+	var b bytes.Buffer
+	defer func(w io.Writer) { logOutput = w }(logOutput)
+	logOutput = &b
+	mux := newWebMux(newMemoryStore(1<<20), nil)
+
+	tests := []struct {
+		header string
+		wantID string // a pattern
+	}{
+		{"trace-42", "^trace-42$"},
+		{"", "^[0-9a-f]{16}$"},
+		{"not valid!", "^[0-9a-f]{16}$"},
+	}
+	for _, test := range tests {
+		b.Reset()
+		req := httptest.NewRequest("GET", "/healthz?probe=1", nil)
+		if test.header != "" {
+			req.Header.Set(requestIDHeader, test.header)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		id := w.Header().Get(requestIDHeader)
+		if !regexp.MustCompile(test.wantID).MatchString(id) {
+			t.Errorf("TestRequestLog => X-Request-ID %q got %q, want %s", test.header, id, test.wantID)
+		}
+		var entry struct {
+			Level, Msg, Method, Path, Route string
+			RequestID                       string `json:"request_id"`
+			Status, Bytes                   int
+		}
+		if err := json.Unmarshal(b.Bytes(), &entry); err != nil || strings.Count(b.String(), "\n") != 1 {
+			t.Fatalf("TestRequestLog => got log %q: %v", b.String(), err)
+		}
+		want := "info request GET /healthz /healthz " + id + " 200 3"
+		got := fmt.Sprintf("%s %s %s %s %s %s %d %d",
+			entry.Level, entry.Msg, entry.Method, entry.Path, entry.Route, entry.RequestID, entry.Status, entry.Bytes)
+		if got != want {
+			t.Errorf("TestRequestLog => got log %q, want %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,577 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/Laugusti/sequencediagram"
+)
+
+// JSON-RPC error codes used by the language server
+const (
+	lspParseError     = -32700
+	lspMethodNotFound = -32601
+	lspInvalidParams  = -32602
+	lspRequestFailed  = -32803
+)
+
+// LSP diagnostic severity of parse errors
+const lspSeverityError = 1
+
+// lspKeywords are offered by completion together with the participant names
+var lspKeywords = []string{"title ", "participant ", "note left of ", "note right of ", "box \"", "end box", "loop ", "end", "== "}
+
+// lsp runs a language server for sequence diagram files on stdin and stdout
+func lsp(args []string) {
+	fs := flag.NewFlagSet("lsp", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s lsp\n", os.Args[0])
+	}
+	fs.Parse(args)
+	s := newLSPServer(os.Stdout)
+	if err := s.serve(os.Stdin); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	// the exit notification without a shutdown request is an error
+	if !s.shutdown {
+		os.Exit(1)
+	}
+}
+
+type lspMessage struct {
+	ID     *json.RawMessage `json:"id"`
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *lspError) Error() string { return e.Message }
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspTextDocument struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+type lspPositionParams struct {
+	TextDocument lspTextDocument `json:"textDocument"`
+	Position     lspPosition     `json:"position"`
+	NewName      string          `json:"newName"`
+}
+
+// lspDocument is an open sequence diagram file, sd is the diagram of the last
+// version of the text without parse errors
+type lspDocument struct {
+	text string
+	sd   *sequencediagram.Diagram
+}
+
+type lspServer struct {
+	w        *bufio.Writer
+	docs     map[string]*lspDocument
+	shutdown bool
+}
+
+func newLSPServer(w io.Writer) *lspServer {
+	return &lspServer{w: bufio.NewWriter(w), docs: make(map[string]*lspDocument)}
+}
+
+// serve handles messages until the exit notification or the end of r
+func (s *lspServer) serve(r io.Reader) error {
+	in := bufio.NewReader(r)
+	for {
+		body, err := readLSPMessage(in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var msg lspMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			if err := s.reply(nil, nil, &lspError{lspParseError, err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+		result, rpcErr := s.handle(msg.Method, msg.Params)
+		// notifications have no id and get no response
+		if msg.ID != nil {
+			if err := s.reply(msg.ID, result, rpcErr); err != nil {
+				return err
+			}
+		} else if rpcErr != nil && rpcErr.Code != lspMethodNotFound {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", msg.Method, rpcErr)
+		}
+	}
+}
+
+// readLSPMessage reads the body of a message with a Content-Length header
+func readLSPMessage(r *bufio.Reader) ([]byte, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF || len(header) == 0 && err == io.ErrUnexpectedEOF {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func (s *lspServer) write(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n%s", len(b), b); err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
+
+func (s *lspServer) reply(id *json.RawMessage, result interface{}, err *lspError) error {
+	response := map[string]interface{}{"jsonrpc": "2.0", "id": id}
+	if err != nil {
+		response["error"] = err
+	} else {
+		response["result"] = result
+	}
+	return s.write(response)
+}
+
+func (s *lspServer) notify(method string, params interface{}) error {
+	return s.write(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (s *lspServer) handle(method string, raw json.RawMessage) (interface{}, *lspError) {
+	var params lspPositionParams
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return nil, &lspError{lspInvalidParams, err.Error()}
+		}
+	}
+	switch method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":           1, // full text on every change
+				"completionProvider":         map[string]interface{}{},
+				"hoverProvider":              true,
+				"definitionProvider":         true,
+				"renameProvider":             true,
+				"documentFormattingProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "textdiag"},
+		}, nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		return nil, s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var change struct {
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := json.Unmarshal(raw, &change); err != nil || len(change.ContentChanges) == 0 {
+			return nil, &lspError{lspInvalidParams, "Missing content changes."}
+		}
+		return nil, s.update(params.TextDocument.URI, change.ContentChanges[len(change.ContentChanges)-1].Text)
+	case "textDocument/didClose":
+		delete(s.docs, params.TextDocument.URI)
+		return nil, nil
+	}
+
+	doc, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		if strings.HasPrefix(method, "textDocument/") {
+			return nil, &lspError{lspInvalidParams, "Document is not open."}
+		}
+		return nil, &lspError{lspMethodNotFound, "Method not found: " + method}
+	}
+	uri := params.TextDocument.URI
+	switch method {
+	case "textDocument/completion":
+		return doc.completion(), nil
+	case "textDocument/hover":
+		return doc.hover(params.Position), nil
+	case "textDocument/definition":
+		return doc.definition(uri, params.Position), nil
+	case "textDocument/rename":
+		return doc.rename(uri, params.Position, params.NewName)
+	case "textDocument/formatting":
+		return doc.format()
+	}
+	return nil, &lspError{lspMethodNotFound, "Method not found: " + method}
+}
+
+// update stores the new text of a document and publishes its parse errors
+func (s *lspServer) update(uri, text string) *lspError {
+	doc, ok := s.docs[uri]
+	if !ok {
+		doc = &lspDocument{}
+		s.docs[uri] = doc
+	}
+	doc.text = text
+	diagnostics := []lspDiagnostic{}
+	if sd, err := parseSource(text); err != nil {
+		diagnostics = append(diagnostics, doc.diagnostic(err))
+	} else {
+		doc.sd = sd
+	}
+	if err := s.notify("textDocument/publishDiagnostics", map[string]interface{}{"uri": uri, "diagnostics": diagnostics}); err != nil {
+		return &lspError{lspRequestFailed, err.Error()}
+	}
+	return nil
+}
+
+// diagnostic covers the line of a "Line N: message" error, or the first line
+func (doc *lspDocument) diagnostic(err error) lspDiagnostic {
+	line, message := 0, err.Error()
+	if match := lineErrorPattern.FindStringSubmatch(message); match != nil {
+		n, _ := strconv.Atoi(match[1])
+		line, message = n-1, match[2]
+	}
+	lines := doc.lines()
+	end := 0
+	if line < len(lines) {
+		end = utf16Column(lines[line], len(lines[line]))
+	}
+	return lspDiagnostic{lspRange{lspPosition{line, 0}, lspPosition{line, end}}, lspSeverityError, "textdiag", message}
+}
+
+func (doc *lspDocument) lines() []string {
+	return strings.Split(strings.Replace(doc.text, "\r\n", "\n", -1), "\n")
+}
+
+func (doc *lspDocument) completion() []map[string]interface{} {
+	items := []map[string]interface{}{}
+	if doc.sd != nil {
+		for _, node := range doc.sd.GetOrderedNodes() {
+			items = append(items, map[string]interface{}{"label": node.Name, "kind": 6, "detail": "participant"}) // variable
+		}
+	}
+	for _, keyword := range lspKeywords {
+		items = append(items, map[string]interface{}{"label": strings.TrimRight(keyword, " \""), "kind": 14, "insertText": keyword}) // keyword
+	}
+	return items
+}
+
+// at returns the participant name at the position
+func (doc *lspDocument) at(pos lspPosition) (participantRef, bool) {
+	lines := doc.lines()
+	if pos.Line < 0 || pos.Line >= len(lines) {
+		return participantRef{}, false
+	}
+	offset := byteOffset(lines[pos.Line], pos.Character)
+	for _, ref := range participantRefs(lines) {
+		if ref.line == pos.Line && ref.start <= offset && offset <= ref.end {
+			return ref, true
+		}
+	}
+	return participantRef{}, false
+}
+
+func (doc *lspDocument) hover(pos lspPosition) interface{} {
+	ref, ok := doc.at(pos)
+	if !ok {
+		return nil
+	}
+	count := 0
+	if doc.sd != nil {
+		for _, message := range doc.sd.Messages() {
+			switch m := message.(type) {
+			case sequencediagram.SelfMessage:
+				if m.Self.Name == ref.name {
+					count++
+				}
+			case sequencediagram.ForwardMessage:
+				if m.From.Name == ref.name || m.To.Name == ref.name {
+					count++
+				}
+			case sequencediagram.BackwardMessage:
+				if m.From.Name == ref.name || m.To.Name == ref.name {
+					count++
+				}
+			}
+		}
+	}
+	plural := "s"
+	if count == 1 {
+		plural = ""
+	}
+	return map[string]interface{}{
+		"contents": map[string]string{"kind": "markdown", "value": fmt.Sprintf("**%s**: %d message%s", ref.name, count, plural)},
+		"range":    doc.refRange(ref),
+	}
+}
+
+// definition is the participant declaration of the name at the position, or
+// its first use if it is not declared
+func (doc *lspDocument) definition(uri string, pos lspPosition) interface{} {
+	ref, ok := doc.at(pos)
+	if !ok {
+		return nil
+	}
+	var first *participantRef
+	for _, r := range participantRefs(doc.lines()) {
+		if r.name != ref.name {
+			continue
+		}
+		if r.declaration {
+			return lspLocation{uri, doc.refRange(r)}
+		}
+		if first == nil {
+			r := r
+			first = &r
+		}
+	}
+	return lspLocation{uri, doc.refRange(*first)}
+}
+
+// rename replaces every use of the participant at the position. The new name
+// must keep the diagram valid and cannot be the name of another participant.
+func (doc *lspDocument) rename(uri string, pos lspPosition, newName string) (interface{}, *lspError) {
+	ref, ok := doc.at(pos)
+	if !ok {
+		return nil, nil
+	}
+	if newName == ref.name {
+		return map[string]interface{}{"changes": map[string][]lspTextEdit{uri: {}}}, nil
+	}
+	before, err := parseSource(doc.text)
+	if err != nil {
+		return nil, &lspError{lspRequestFailed, "Cannot rename in a diagram with errors."}
+	}
+	lines := doc.lines()
+	for _, r := range participantRefs(lines) {
+		if r.name == newName {
+			return nil, &lspError{lspRequestFailed, fmt.Sprintf("Participant %s already exists.", newName)}
+		}
+	}
+	edits := []lspTextEdit{}
+	renamed := append([]string(nil), lines...)
+	refs := participantRefs(lines)
+	// later references are replaced first so earlier offsets stay valid
+	for i := len(refs) - 1; i >= 0; i-- {
+		r := refs[i]
+		if r.name != ref.name {
+			continue
+		}
+		edits = append([]lspTextEdit{{doc.refRange(r), newName}}, edits...)
+		renamed[r.line] = renamed[r.line][:r.start] + newName + renamed[r.line][r.end:]
+	}
+	// the other participants must be read the same way after the rename
+	after, err := parseSource(strings.Join(renamed, "\n"))
+	if err != nil || !renamedNodes(before, after, ref.name, newName) {
+		return nil, &lspError{lspRequestFailed, fmt.Sprintf("Invalid participant name %q.", newName)}
+	}
+	return map[string]interface{}{"changes": map[string][]lspTextEdit{uri: edits}}, nil
+}
+
+// renamedNodes reports whether after has the participants of before with
+// oldName replaced by newName
+func renamedNodes(before, after *sequencediagram.Diagram, oldName, newName string) bool {
+	want := make(map[string]bool)
+	for _, node := range before.GetOrderedNodes() {
+		if node.Name == oldName {
+			want[newName] = true
+		} else {
+			want[node.Name] = true
+		}
+	}
+	got := after.GetOrderedNodes()
+	if len(got) != len(want) {
+		return false
+	}
+	for _, node := range got {
+		if !want[node.Name] {
+			return false
+		}
+	}
+	return true
+}
+
+// format replaces the document with its formatted text
+func (doc *lspDocument) format() (interface{}, *lspError) {
+	formatted, err := formatSource(doc.text)
+	if err != nil {
+		return nil, &lspError{lspRequestFailed, err.Error()}
+	}
+	if formatted == doc.text {
+		return []lspTextEdit{}, nil
+	}
+	lines := strings.Split(doc.text, "\n")
+	last := len(lines) - 1
+	end := lspPosition{last, utf16Column(lines[last], len(lines[last]))}
+	return []lspTextEdit{{lspRange{lspPosition{0, 0}, end}, formatted}}, nil
+}
+
+func (doc *lspDocument) refRange(ref participantRef) lspRange {
+	line := doc.lines()[ref.line]
+	return lspRange{lspPosition{ref.line, utf16Column(line, ref.start)}, lspPosition{ref.line, utf16Column(line, ref.end)}}
+}
+
+// participantRef is a participant name in the source, start and end are byte
+// offsets in the line
+type participantRef struct {
+	line, start, end int
+	name             string
+	declaration      bool
+}
+
+var (
+	lspBoxPattern         = regexp.MustCompile(`^box ".+"$`)
+	lspParticipantPattern = regexp.MustCompile(`^participant (?:"(.+)"|(.+?))( order -?[0-9]+)?$`)
+	lspNotePattern        = regexp.MustCompile("^note (?:right|left) of (.+):.+$")
+	lspArrowPattern       = regexp.MustCompile("--?>>?")
+)
+
+// participantRefs finds the participant names in the lines the way
+// ParseFromText reads them
+func participantRefs(lines []string) []participantRef {
+	var refs []participantRef
+	for i, line := range lines {
+		switch {
+		case lspBoxPattern.MatchString(line), strings.HasPrefix(line, "title "):
+		case lspParticipantPattern.MatchString(line):
+			m := lspParticipantPattern.FindStringSubmatchIndex(line)
+			// the name is inside the quotes of a quoted name
+			if m[2] < 0 {
+				m = m[2:]
+			}
+			refs = append(refs, participantRef{i, m[2], m[3], line[m[2]:m[3]], true})
+		case strings.HasPrefix(line, "== ") && strings.HasSuffix(line, " =="):
+		case lspArrowPattern.MatchString(line) && strings.Contains(line, ":"):
+			arrow := lspArrowPattern.FindString(line)
+			m := regexp.MustCompile("^(.+)" + arrow + "(.+):(.+)$").FindStringSubmatchIndex(line)
+			if m == nil {
+				continue
+			}
+			refs = append(refs, participantRef{i, m[2], m[3], line[m[2]:m[3]], false})
+			refs = append(refs, participantRef{i, m[4], m[5], line[m[4]:m[5]], false})
+		case lspNotePattern.MatchString(line):
+			m := lspNotePattern.FindStringSubmatchIndex(line)
+			refs = append(refs, participantRef{i, m[2], m[3], line[m[2]:m[3]], false})
+		}
+	}
+	return refs
+}
+
+var (
+	formatMessagePattern = regexp.MustCompile(`^(.+?)\s*(--?>>?)\s*(.+?)\s*:\s*(.+)$`)
+	formatNotePattern    = regexp.MustCompile(`^note\s+(right|left)\s+of\s+(.+?)\s*:\s*(.+)$`)
+	formatBlockPattern   = regexp.MustCompile(`^(box\s+".+"|end(\s+box)?)$`)
+	formatKeywordPattern = regexp.MustCompile(`^(title|participant|loop)\s+(.+)$`)
+	formatSpacePattern   = regexp.MustCompile(`\s+`)
+)
+
+// formatSource removes blank lines and the spaces around keywords, arrows and
+// colons, and returns the text of the parsed diagram with a final newline
+func formatSource(text string) (string, error) {
+	var lines []string
+	for _, line := range strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "=="):
+			line = "== " + strings.TrimSpace(strings.Trim(line, "=")) + " =="
+		case formatBlockPattern.MatchString(line):
+			line = formatSpacePattern.ReplaceAllString(line, " ")
+		case formatKeywordPattern.MatchString(line):
+			m := formatKeywordPattern.FindStringSubmatch(line)
+			line = m[1] + " " + m[2]
+			if m[1] == "participant" {
+				line = m[1] + " " + formatSpacePattern.ReplaceAllString(m[2], " ")
+			}
+		case formatMessagePattern.MatchString(line):
+			m := formatMessagePattern.FindStringSubmatch(line)
+			line = m[1] + m[2] + m[3] + ":" + m[4]
+		case formatNotePattern.MatchString(line):
+			m := formatNotePattern.FindStringSubmatch(line)
+			line = "note " + m[1] + " of " + m[2] + ":" + m[3]
+		}
+		lines = append(lines, line)
+	}
+	sd, err := sequencediagram.ParseFromText(strings.Join(lines, "\n"))
+	if err != nil {
+		return "", err
+	}
+	return sd.String() + "\n", nil
+}
+
+// utf16Column is the LSP character offset of a byte offset in the line
+func utf16Column(line string, offset int) int {
+	column := 0
+	for _, r := range line[:offset] {
+		column += len(utf16.Encode([]rune{r}))
+	}
+	return column
+}
+
+// byteOffset is the byte offset of an LSP character offset in the line
+func byteOffset(line string, column int) int {
+	offset := 0
+	for column > 0 && offset < len(line) {
+		r, size := utf8.DecodeRuneInString(line[offset:])
+		column -= len(utf16.Encode([]rune{r}))
+		offset += size
+	}
+	return offset
+}
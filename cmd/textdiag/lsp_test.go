@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// lspSession runs the server on the requests and returns the messages it sent
+func lspSession(t *testing.T, requests ...string) []map[string]interface{} {
+	var in, out bytes.Buffer
+	for _, request := range requests {
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(request), request)
+	}
+	s := newLSPServer(&out)
+	if err := s.serve(&in); err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+	var messages []map[string]interface{}
+	r := bufio.NewReader(&out)
+	for {
+		body, err := readLSPMessage(r)
+		if err != nil {
+			break
+		}
+		var msg map[string]interface{}
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatalf("invalid response %s: %v", body, err)
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+func lspRequest(id int, method string, params interface{}) string {
+	b, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+	return string(b)
+}
+
+func lspNotification(method string, params interface{}) string {
+	b, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+	return string(b)
+}
+
+// lspJSON is v as it is decoded from a message
+func lspJSON(v interface{}) interface{} {
+	b, _ := json.Marshal(v)
+	var result interface{}
+	json.Unmarshal(b, &result)
+	return result
+}
+
+const lspURI = "file:///flow.sd"
+
+func lspAt(line, character int) map[string]interface{} {
+	return map[string]interface{}{"textDocument": map[string]string{"uri": lspURI}, "position": lspPosition{line, character}}
+}
+
+func TestLSPDiagnostics(t *testing.T) {
+	messages := lspSession(t,
+		lspNotification("textDocument/didOpen", map[string]interface{}{"textDocument": map[string]string{"uri": lspURI, "text": "a->b:x\nbad line\n"}}),
+		lspNotification("textDocument/didChange", map[string]interface{}{"textDocument": map[string]string{"uri": lspURI},
+			"contentChanges": []map[string]string{{"text": "a->b:x\n"}}}),
+	)
+	want := []interface{}{
+		lspJSON([]lspDiagnostic{{lspRange{lspPosition{1, 0}, lspPosition{1, 8}}, lspSeverityError, "textdiag", "Syntax error."}}),
+		[]interface{}{},
+	}
+	if len(messages) != len(want) {
+		t.Fatalf("TestLSPDiagnostics => got %d messages, want %d: %v", len(messages), len(want), messages)
+	}
+	for i, msg := range messages {
+		params := msg["params"].(map[string]interface{})
+		if msg["method"] != "textDocument/publishDiagnostics" || params["uri"] != lspURI || !reflect.DeepEqual(params["diagnostics"], want[i]) {
+			t.Errorf("TestLSPDiagnostics => got %v, want diagnostics %v", msg, want[i])
+		}
+	}
+}
+
+func TestLSPRequests(t *testing.T) {
+	text := "participant Client\nClient->Server:GET /\nServer-->Client:200 OK\nnote right of Server:é"
+	open := lspNotification("textDocument/didOpen", map[string]interface{}{"textDocument": map[string]string{"uri": lspURI, "text": text}})
+	rename := lspAt(1, 10)
+	rename["newName"] = "Backend"
+	tests := []struct {
+		method string
+		params interface{}
+		want   interface{}
+	}{
+		{"textDocument/hover", lspAt(1, 3), map[string]interface{}{
+			"contents": map[string]string{"kind": "markdown", "value": "**Client**: 2 messages"},
+			"range":    lspRange{lspPosition{1, 0}, lspPosition{1, 6}},
+		}},
+		{"textDocument/hover", lspAt(1, 7), nil},
+		{"textDocument/definition", lspAt(2, 0), lspLocation{lspURI, lspRange{lspPosition{1, 8}, lspPosition{1, 14}}}},
+		{"textDocument/definition", lspAt(2, 10), lspLocation{lspURI, lspRange{lspPosition{0, 12}, lspPosition{0, 18}}}},
+		{"textDocument/rename", rename, map[string]interface{}{"changes": map[string][]lspTextEdit{lspURI: {
+			{lspRange{lspPosition{1, 8}, lspPosition{1, 14}}, "Backend"},
+			{lspRange{lspPosition{2, 0}, lspPosition{2, 6}}, "Backend"},
+			{lspRange{lspPosition{3, 14}, lspPosition{3, 20}}, "Backend"},
+		}}}},
+		{"textDocument/formatting", lspAt(0, 0), []lspTextEdit{{lspRange{lspPosition{0, 0}, lspPosition{3, 22}}, text + "\n"}}},
+	}
+	for i, test := range tests {
+		messages := lspSession(t, open, lspRequest(i+1, test.method, test.params))
+		if len(messages) != 2 {
+			t.Fatalf("TestLSPRequests => %s got %d messages, want 2: %v", test.method, len(messages), messages)
+		}
+		if got := messages[1]["result"]; !reflect.DeepEqual(got, lspJSON(test.want)) || messages[1]["id"] != float64(i+1) {
+			t.Errorf("TestLSPRequests => %s %v got %v, want %v", test.method, test.params, messages[1], lspJSON(test.want))
+		}
+	}
+}
+
+func TestLSPCompletion(t *testing.T) {
+	messages := lspSession(t,
+		lspNotification("textDocument/didOpen", map[string]interface{}{"textDocument": map[string]string{"uri": lspURI, "text": "b->a:x"}}),
+		// the participants of the last valid text are still completed
+		lspNotification("textDocument/didChange", map[string]interface{}{"textDocument": map[string]string{"uri": lspURI},
+			"contentChanges": []map[string]string{{"text": "b->a:x\nc"}}}),
+		lspRequest(1, "textDocument/completion", lspAt(1, 1)),
+	)
+	var labels []string
+	for _, item := range messages[len(messages)-1]["result"].([]interface{}) {
+		labels = append(labels, item.(map[string]interface{})["label"].(string))
+	}
+	want := "b a title participant note left of note right of box end box loop end =="
+	if got := strings.Join(labels, " "); got != want {
+		t.Errorf("TestLSPCompletion => got %q, want %q", got, want)
+	}
+}
+
+func TestLSPErrors(t *testing.T) {
+	rename := lspAt(1, 3)
+	rename["newName"] = "b"
+	invalid := lspAt(1, 0)
+	invalid["newName"] = "x->y"
+	messages := lspSession(t,
+		lspNotification("textDocument/didOpen", map[string]interface{}{"textDocument": map[string]string{"uri": lspURI, "text": "a->b:x\nb->a:y"}}),
+		lspRequest(1, "textDocument/rename", rename),
+		lspRequest(2, "textDocument/rename", invalid),
+		lspRequest(3, "textDocument/hover", map[string]interface{}{"textDocument": map[string]string{"uri": "file:///other.sd"}}),
+		lspRequest(4, "workspace/symbol", map[string]interface{}{}),
+		lspRequest(5, "shutdown", nil),
+		lspNotification("exit", nil),
+		lspRequest(6, "shutdown", nil),
+	)
+	want := []string{"Participant b already exists.", `Invalid participant name "x->y".`, "Document is not open.", "Method not found: workspace/symbol"}
+	if len(messages) != 6 {
+		t.Fatalf("TestLSPErrors => got %d messages, want 6: %v", len(messages), messages)
+	}
+	for i, w := range want {
+		err, _ := messages[i+1]["error"].(map[string]interface{})
+		if err == nil || err["message"] != w {
+			t.Errorf("TestLSPErrors => got %v, want error %q", messages[i+1], w)
+		}
+	}
+	if result, ok := messages[5]["result"]; !ok || result != nil {
+		t.Errorf("TestLSPErrors => shutdown got %v, want null result", messages[5])
+	}
+}
+
+func TestFormatSource(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+		err  string
+	}{
+		{"title  T\n\n  participant   A order 2\nA -> B : hello\r\nB -->> A:  ok  \nnote  left of A : n\n==  x ==\nloop  every 5s\nA->A:poll\nend\n", "title T\nparticipant A order 2\nA->B:hello\nB-->>A:ok\nnote left of A:n\n== x ==\nloop every 5s\nA->A:poll\nend\n", ""},
+		{`box   "Backend"` + "\nparticipant S\nend   box", "box \"Backend\"\nparticipant S\nend box\n", ""},
+		{"a->b:x\nbad", "", "Line 2: Syntax error."},
+	}
+	for _, test := range tests {
+		got, err := formatSource(test.src)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("TestFormatSource => input: %q, got error %v, want %q", test.src, err, test.err)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("TestFormatSource => input: %q, got %q (%v), want %q", test.src, got, err, test.want)
+		}
+	}
+}
+
+func TestParticipantRefsQuoted(t *testing.T) {
+	lines := []string{`participant "Sort order 3" order 1`, "participant Sort order 2", "a->Sort order 3:x"}
+	want := []participantRef{
+		{0, 13, 25, "Sort order 3", true},
+		{1, 12, 16, "Sort", true},
+		{2, 0, 1, "a", false},
+		{2, 3, 15, "Sort order 3", false},
+	}
+	if got := participantRefs(lines); !reflect.DeepEqual(got, want) {
+		t.Errorf("TestParticipantRefsQuoted => got %v, want %v", got, want)
+	}
+}
@@ -2,27 +2,52 @@ package main
 
 import (
 	"bufio"
-	"bytes"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"log"
-	"net/http"
 	"os"
+	"regexp"
 
 	"github.com/Laugusti/sequencediagram"
 	"github.com/Laugusti/sequencediagram/textdiagram"
 )
 
-type result struct {
-	Diagram string
-	Error   string
+var mode = flag.String("mode", "web", "valid modes are cmd or web")
+
+// commands are run with the arguments that follow the command name
+var commands = map[string]func(args []string){
+	"render":       render,
+	"repl":         repl,
+	"watch":        watch,
+	"markdown":     markdown,
+	"lint":         lintCommand,
+	"lsp":          lsp,
+	"import-trace": importTrace,
+	"import-har":   importHAR,
 }
 
-var mode = flag.String("mode", "web", "valid modes are cmd or web")
+// synopses are the usage lines of the commands
+var synopses = []string{
+	"[-mode cmd|web] [-addr address] [-tls-cert file -tls-key file] [-read-timeout d] [-write-timeout d] [-idle-timeout d] [-shutdown-timeout d] [-store-dir dir] [-memory-store-size bytes] [-allowed-origins list]",
+	"render [-i file] [-o file] [-format text|svg|json]",
+	"repl",
+	"watch [-interval duration] [-write] [-format text|svg|json] file...",
+	"markdown [-check] [-o file] file...",
+	"lint [-config file] file...",
+	"lsp",
+	"import-trace [-source] file...",
+	"import-har [-source] [-host pattern] [-type pattern] file...",
+}
 
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			command(os.Args[2:])
+			return
+		}
+	}
+	flag.Usage = usage
 	flag.Parse()
 
 	if *mode != "web" && *mode != "cmd" {
@@ -36,58 +61,46 @@ func main() {
 	}
 }
 
-func webServer() {
-	http.Handle("/favicon.ico", http.NotFoundHandler())
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "textSD.html")
-	})
-	http.HandleFunc("/creatediagram", func(w http.ResponseWriter, r *http.Request) {
-		buf := &bytes.Buffer{}
-		io.Copy(buf, r.Body) // NOTE: ignoring error
-		sd, err := sequencediagram.ParseFromText(buf.String())
-		if err != nil {
-			log.Printf("failed to parse sequence diagram: %v", err)
-			b, err := json.Marshal(result{"", err.Error()})
-			if err != nil {
-				log.Printf("failed to marshal result: %v", err)
-				fmt.Fprintf(w, "failed to marshal result: %v", err)
-				return
-			}
-			w.Write(b)
-			return
-		}
-		buf.Reset()
-		io.Copy(buf, textdiagram.Encode(sd))
-		b, err := json.Marshal(result{buf.String(), ""})
-		if err != nil {
-			log.Printf("failed to marshal result: %v", err)
-			fmt.Fprintf(w, "failed to marshal result: %v", err)
-			return
+func usage() {
+	for i, synopsis := range synopses {
+		prefix := "Usage:"
+		if i > 0 {
+			prefix = "      "
 		}
-		log.Println("successfully parsed sequence diagram")
-		w.Write(b)
-	})
-	log.Fatal(http.ListenAndServe(":8080", nil))
+		fmt.Fprintf(flag.CommandLine.Output(), "%s %s %s\n", prefix, os.Args[0], synopsis)
+	}
+	flag.PrintDefaults()
 }
 
 func commandLine() {
-	input := bufio.NewScanner(os.Stdin)
+	runREPL(os.Stdin, os.Stdout, log.New(os.Stderr, "", log.LstdFlags))
+}
+
+// unclosedBlockPattern matches the errors of a box or loop that is not ended
+// yet, which are expected while such a block is typed line by line
+var unclosedBlockPattern = regexp.MustCompile(`^Line [0-9]+: (Box|Loop) is never ended\.$`)
+
+// runREPL reads a sequence diagram line by line from r and writes the diagram
+// to w after each line that keeps it valid. A line is kept if the diagram with
+// it parses, or only lacks the end of a box or loop.
+func runREPL(r io.Reader, w io.Writer, errs *log.Logger) {
+	input := bufio.NewScanner(r)
 	var validLines string
 	for input.Scan() {
-		line := input.Text()
-		sd, err := sequencediagram.ParseFromText(line)
-
-		if err != nil {
-			log.Println(err)
+		source := input.Text()
+		if len(validLines) > 0 {
+			source = validLines + "\n" + source
+		}
+		sd, err := sequencediagram.ParseFromText(source)
+		if err != nil && !unclosedBlockPattern.MatchString(err.Error()) {
+			errs.Println(err)
 			continue
 		}
-		if len(validLines) > 0 {
-			validLines += "\n"
+		validLines = source
+		if err != nil {
+			continue
 		}
-		validLines += line
-		fmt.Println("\n")
-		// NOTE: ignoring errors
-		sd, _ = sequencediagram.ParseFromText(validLines)
-		io.Copy(os.Stdout, textdiagram.Encode(sd))
+		fmt.Fprint(w, "\n\n")
+		io.Copy(w, textdiagram.Encode(sd))
 	}
 }
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/Laugusti/sequencediagram/textdiagram"
+)
+
+const (
+	// the rendered diagram of a sequence block is kept between these markers
+	markdownBegin = "<!-- textdiag -->"
+	markdownEnd   = "<!-- /textdiag -->"
+)
+
+var (
+	fencePattern         = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})\\s*([^`]*)$")
+	sequenceInfoPattern  = regexp.MustCompile("^sequence(\\s|$)")
+	closingFenceTemplate = "^ {0,3}%s%s*\\s*$"
+)
+
+// markdown renders the sequence blocks of Markdown files
+func markdown(args []string) {
+	fs := flag.NewFlagSet("markdown", flag.ExitOnError)
+	check := fs.Bool("check", false, "report files whose rendered diagrams are out of date instead of writing them")
+	output := fs.String("o", "", "write a copy with each sequence block replaced by its diagram (one input file only)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s markdown [-check] [-o file] file...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 || (*output != "" && fs.NArg() > 1) {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, name := range fs.Args() {
+		if err := markdownFile(os.Stdout, name, *output, *check); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// markdownFile renders the sequence blocks of the named file, into the file
+// itself or into a copy if output is set. With check nothing is written and an
+// error is returned if the destination is not up to date.
+func markdownFile(w io.Writer, name, output string, check bool) error {
+	src, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	dest := name
+	if output != "" {
+		dest = output
+	}
+	rendered, err := renderMarkdown(name, src, output == "")
+	if err != nil {
+		return err
+	}
+	current, err := ioutil.ReadFile(dest)
+	if err != nil && !(os.IsNotExist(err) && !check) {
+		return err
+	}
+	if bytes.Equal(current, rendered) {
+		return nil
+	}
+	if check {
+		return fmt.Errorf("%s: sequence diagrams are out of date, run %s markdown", dest, os.Args[0])
+	}
+	if err := ioutil.WriteFile(dest, rendered, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "updated %s\n", dest)
+	return nil
+}
+
+// renderMarkdown renders each ```sequence block of the Markdown file src. If
+// inPlace is set, the diagram is inserted after the block between markers, or
+// replaces the diagram that is already there. Otherwise the block itself is
+// replaced by the diagram. Other fenced blocks are copied as is.
+func renderMarkdown(name string, src []byte, inPlace bool) ([]byte, error) {
+	lines := strings.Split(string(src), "\n")
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		match := fencePattern.FindStringSubmatch(lines[i])
+		if match == nil {
+			out = append(out, lines[i])
+			continue
+		}
+		closing := regexp.MustCompile(fmt.Sprintf(closingFenceTemplate, regexp.QuoteMeta(match[1]), regexp.QuoteMeta(match[1][:1])))
+		end := i + 1
+		for end < len(lines) && !closing.MatchString(lines[end]) {
+			end++
+		}
+		if !sequenceInfoPattern.MatchString(strings.TrimSpace(match[2])) {
+			// copy other fenced blocks, which may contain example sequence blocks
+			if end == len(lines) {
+				end--
+			}
+			out = append(out, lines[i:end+1]...)
+			i = end
+			continue
+		}
+		if end == len(lines) {
+			return nil, fmt.Errorf("%s:%d: Sequence block is never closed.", name, i+1)
+		}
+
+		sd, err := parseSource(strings.Join(lines[i+1:end], "\n"))
+		if err != nil {
+			return nil, positionalErrorAt(name, i+1, err)
+		}
+		b, _ := ioutil.ReadAll(textdiagram.Encode(sd))
+		diagram := strings.Split(string(b), "\n")
+		// editors often strip trailing spaces, which would make the file drift
+		for j := range diagram {
+			diagram[j] = strings.TrimRight(diagram[j], " ")
+		}
+		block := append(append([]string{"```text"}, diagram...), "```")
+
+		if inPlace {
+			out = append(out, lines[i:end+1]...)
+			out = append(out, "", markdownBegin)
+			out = append(out, block...)
+			out = append(out, markdownEnd)
+		} else {
+			out = append(out, block...)
+		}
+		i = end
+		// drop the diagram rendered before
+		next := end + 1
+		for next < len(lines) && strings.TrimSpace(lines[next]) == "" {
+			next++
+		}
+		if next < len(lines) && strings.TrimSpace(lines[next]) == markdownBegin {
+			for j := next; j < len(lines); j++ {
+				if strings.TrimSpace(lines[j]) == markdownEnd {
+					i = j
+					break
+				}
+			}
+		}
+	}
+	return []byte(strings.Join(out, "\n")), nil
+}
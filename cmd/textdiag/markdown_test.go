@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const diagramAB = "```text\n┌───┐     ┌───┐\n│ a │     │ b │\n└───┘     └───┘\n  │  ┌───┐  │\n   ──┤ x ├─▶\n  │  └───┘  │\n┌───┐     ┌───┐\n│ a │     │ b │\n└───┘     └───┘\n```"
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		src     string
+		inPlace bool
+		want    string
+	}{
+		{"# Doc\n\n```sequence\na->b:x\n```\n\nText\n", true,
+			"# Doc\n\n```sequence\na->b:x\n```\n\n" + markdownBegin + "\n" + diagramAB + "\n" + markdownEnd + "\n\nText\n"},
+		// an outdated diagram is replaced
+		{"```sequence\na->b:x\n```\n\n" + markdownBegin + "\n```text\nold\n```\n" + markdownEnd + "\nText", true,
+			"```sequence\na->b:x\n```\n\n" + markdownBegin + "\n" + diagramAB + "\n" + markdownEnd + "\nText"},
+		{"# Doc\n\n~~~~ sequence\na->b:x\n~~~~\nText", false, "# Doc\n\n" + diagramAB + "\nText"},
+		{"```sequence\na->b:x\n```\n" + markdownBegin + "\n```text\nold\n```\n" + markdownEnd, false, diagramAB},
+		// sequence blocks inside other blocks are examples
+		{"````markdown\n```sequence\na->b:x\n```\n````", true, "````markdown\n```sequence\na->b:x\n```\n````"},
+		{"```go\nfunc main() {}\n```", true, "```go\nfunc main() {}\n```"},
+	}
+	for _, test := range tests {
+		got, err := renderMarkdown("doc.md", []byte(test.src), test.inPlace)
+		if err != nil {
+			t.Errorf("TestRenderMarkdown => input: %q, got error: %v", test.src, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("TestRenderMarkdown => input: %q, got:\n%s\nwant:\n%s", test.src, got, test.want)
+		}
+		again, err := renderMarkdown("doc.md", got, test.inPlace)
+		if test.inPlace && (err != nil || !bytes.Equal(again, got)) {
+			t.Errorf("TestRenderMarkdown => input: %q, rendering twice got:\n%s\nwant:\n%s", test.src, again, got)
+		}
+	}
+}
+
+func TestRenderMarkdownErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"# Doc\n\n```sequence\na->b:x\nbad\n```", "doc.md:5: Syntax error."},
+		{"# Doc\n```sequence\na->b:x", "doc.md:2: Sequence block is never closed."},
+	}
+	for _, test := range tests {
+		_, err := renderMarkdown("doc.md", []byte(test.src), true)
+		if err == nil || err.Error() != test.want {
+			t.Errorf("TestRenderMarkdownErrors => input: %q, got error %v, want %q", test.src, err, test.want)
+		}
+	}
+}
+
+func TestMarkdownFileCheck(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "README.md")
+	ioutil.WriteFile(name, []byte("```sequence\na->b:x\n```\n"), 0644)
+	var out bytes.Buffer
+	if err := markdownFile(&out, name, "", true); err == nil || !strings.Contains(err.Error(), "out of date") {
+		t.Errorf("TestMarkdownFileCheck => expected drift before rendering, got %v", err)
+	}
+	if err := markdownFile(&out, name, "", false); err != nil {
+		t.Fatalf("TestMarkdownFileCheck => got error: %v", err)
+	}
+	if err := markdownFile(&out, name, "", true); err != nil {
+		t.Errorf("TestMarkdownFileCheck => expected no drift after rendering, got %v", err)
+	}
+	if got, want := out.String(), "updated "+name+"\n"; got != want {
+		t.Errorf("TestMarkdownFileCheck => got output %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import "strings"
+
+// maxMergeCells bounds the size of the line matching table of mergeLines
+const maxMergeCells = 4 << 20
+
+// mergeLines merges the line changes of ours and theirs, two edits of base.
+// Changes to different lines and lines inserted at the same place are both
+// kept, ours first. Where both edits change the same lines theirs wins.
+func mergeLines(base, ours, theirs string) string {
+	b, o, t := splitLines(base), splitLines(ours), splitLines(theirs)
+	ob, tb := matchLines(b, o), matchLines(b, t)
+	if ob == nil || tb == nil {
+		return theirs
+	}
+
+	var merged []string
+	// the lines of each text after the last line of base kept by both edits
+	bi, oi, ti := 0, 0, 0
+	for i := 0; i <= len(b); i++ {
+		// the end of base is a line kept by both edits
+		if i < len(b) && (ob[i] < 0 || tb[i] < 0) {
+			continue
+		}
+		oEnd, tEnd := len(o), len(t)
+		if i < len(b) {
+			oEnd, tEnd = ob[i], tb[i]
+		}
+		baseChunk, oursChunk, theirsChunk := b[bi:i], o[oi:oEnd], t[ti:tEnd]
+		switch {
+		case equalLines(oursChunk, baseChunk):
+			merged = append(merged, theirsChunk...)
+		case equalLines(theirsChunk, baseChunk), equalLines(theirsChunk, oursChunk):
+			merged = append(merged, oursChunk...)
+		case len(baseChunk) == 0:
+			// both edits inserted lines at the same place, both are kept
+			merged = append(append(merged, oursChunk...), theirsChunk...)
+		case len(oursChunk) == len(baseChunk) && len(theirsChunk) == len(baseChunk):
+			// both edits replaced lines, which are merged one by one
+			for j := range baseChunk {
+				if oursChunk[j] != baseChunk[j] && theirsChunk[j] == baseChunk[j] {
+					merged = append(merged, oursChunk[j])
+				} else {
+					merged = append(merged, theirsChunk[j])
+				}
+			}
+		default:
+			// both edits changed the lines, the later edit wins
+			merged = append(merged, theirsChunk...)
+		}
+		if i < len(b) {
+			merged = append(merged, b[i])
+		}
+		bi, oi, ti = i+1, oEnd+1, tEnd+1
+	}
+	return strings.Join(merged, "\n")
+}
+
+// matchLines returns for each line of a the index of the same line in b, or -1
+// if it is not kept, using a longest common subsequence. It returns nil if the
+// texts are too large to compare.
+func matchLines(a, b []string) []int {
+	match := make([]int, len(a))
+	for i := range match {
+		match[i] = -1
+	}
+	// common prefix and suffix lines are matched without the table
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		match[prefix] = prefix
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		match[len(a)-1-suffix] = len(b) - 1 - suffix
+		suffix++
+	}
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if (len(ma)+1)*(len(mb)+1) > maxMergeCells {
+		return nil
+	}
+	// lcs[i][j] is the length of the longest common subsequence of ma[i:] and mb[j:]
+	lcs := make([][]int, len(ma)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(mb)+1)
+	}
+	for i := len(ma) - 1; i >= 0; i-- {
+		for j := len(mb) - 1; j >= 0; j-- {
+			if ma[i] == mb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	for i, j := 0, 0; i < len(ma) && j < len(mb); {
+		switch {
+		case ma[i] == mb[j]:
+			match[prefix+i] = prefix + j
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return match
+}
+
+// splitLines returns the lines of the text, an empty text has none
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestMergeLines(t *testing.T) {
+	tests := []struct {
+		base, ours, theirs string
+		want               string
+	}{
+		{"a->b:x\nb-->a:y", "a->b:x\nb-->a:y", "a->b:x\nb-->a:z", "a->b:x\nb-->a:z"},
+		// changes to different lines are both kept
+		{"a->b:x\nb-->a:y", "a->b:changed\nb-->a:y", "a->b:x\nb-->a:z", "a->b:changed\nb-->a:z"},
+		{"title T\na->b:x", "title T\nparticipant a\na->b:x", "title T\na->b:x\nb-->a:y", "title T\nparticipant a\na->b:x\nb-->a:y"},
+		{"a->b:x\nb->c:y\nc-->b:z", "b->c:y\nc-->b:z", "a->b:x\nb->c:y\nc-->b:Z", "b->c:y\nc-->b:Z"},
+		// lines inserted at the same place by both edits are all kept
+		{"a->b:x", "a->b:x\nb->c:ours", "a->b:x\nc->d:theirs", "a->b:x\nb->c:ours\nc->d:theirs"},
+		{"a->b:x\nb-->a:y", "a->b:x\nb->c:ours\nb-->a:y", "a->b:x\nc->d:theirs\nb-->a:y", "a->b:x\nb->c:ours\nc->d:theirs\nb-->a:y"},
+		{"a->b:x", "title ours\na->b:x", "title theirs\na->b:x", "title ours\ntitle theirs\na->b:x"},
+		{"", "a->b:ours", "c->d:theirs", "a->b:ours\nc->d:theirs"},
+		// the same change by both edits is kept once
+		{"a->b:x", "a->b:x\nb-->a:y", "a->b:x\nb-->a:y", "a->b:x\nb-->a:y"},
+		// the later edit wins when both change the same line
+		{"a->b:x\nb-->a:y", "a->b:ours\nb-->a:y", "a->b:theirs\nb-->a:y", "a->b:theirs\nb-->a:y"},
+		{"", "a->b:x", "", "a->b:x"},
+		{"a->b:x", "a->b:x", "", ""},
+	}
+	for _, test := range tests {
+		if got := mergeLines(test.base, test.ours, test.theirs); got != test.want {
+			t.Errorf("TestMergeLines => base %q, ours %q, theirs %q got %q, want %q", test.base, test.ours, test.theirs, got, test.want)
+		}
+	}
+}
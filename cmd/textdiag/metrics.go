@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Laugusti/sequencediagram"
+)
+
+// metrics of the web server, served by /metrics in the Prometheus text format
+var (
+	httpRequests = newCounterVec("textdiag_http_requests_total",
+		"HTTP requests by route and status code.", "route", "code")
+	renderDuration = newHistogramVec("textdiag_render_duration_seconds",
+		"Time to render a diagram by output format.",
+		[]float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1}, "format")
+	parseErrorCount = newCounterVec("textdiag_parse_errors_total",
+		"Sequence diagrams with parse errors by endpoint.", "endpoint")
+	diagramParticipants = newHistogramVec("textdiag_diagram_participants",
+		"Participants of the parsed diagrams.",
+		[]float64{1, 2, 3, 5, 8, 13, 21, 34}, "endpoint")
+	diagramMessages = newHistogramVec("textdiag_diagram_messages",
+		"Messages of the parsed diagrams, including declarations and notes.",
+		[]float64{1, 5, 10, 25, 50, 100, 250, 500, 1000}, "endpoint")
+
+	allMetrics = []metric{httpRequests, renderDuration, parseErrorCount, diagramParticipants, diagramMessages}
+)
+
+type metric interface {
+	write(w io.Writer)
+}
+
+// labelKey joins label values into a map key
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// formatLabels is the label set of a series, with extra appended
+func formatLabels(names []string, key string, extra ...string) string {
+	var values []string
+	if len(names) > 0 {
+		values = strings.Split(key, "\xff")
+	}
+	var pairs []string
+	for i, name := range names {
+		pairs = append(pairs, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// counterVec is a counter with labels
+type counterVec struct {
+	name, help string
+	labels     []string
+	mu         sync.Mutex
+	values     map[string]float64
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{name: name, help: help, labels: labels, values: make(map[string]float64)}
+}
+
+func (c *counterVec) inc(labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[labelKey(labelValues)]++
+}
+
+// keys returns the series in order so the output is stable
+func (c *counterVec) keys() []string {
+	var keys []string
+	for key := range c.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (c *counterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, key := range c.keys() {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, key), formatFloat(c.values[key]))
+	}
+}
+
+// histogramVec is a histogram with labels
+type histogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+	mu         sync.Mutex
+	series     map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{name: name, help: help, labels: labels, buckets: buckets, series: make(map[string]*histogram)}
+}
+
+func (h *histogramVec) observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := labelKey(labelValues)
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, bound := range h.buckets {
+		if v <= bound {
+			s.counts[i]++
+			break
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+// keys returns the series in order so the output is stable
+func (h *histogramVec) keys() []string {
+	var keys []string
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, key := range h.keys() {
+		s := h.series[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, key), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, key), s.count)
+	}
+}
+
+// serveMetrics writes all metrics in the Prometheus text exposition format
+func serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	for _, m := range allMetrics {
+		m.write(w)
+	}
+}
+
+// parseObserved parses a diagram received by the endpoint, counting parse
+// errors and recording the size of valid diagrams
+func parseObserved(endpoint, source string) (*sequencediagram.Diagram, error) {
+	sd, err := parseSource(source)
+	if err != nil {
+		parseErrorCount.inc(endpoint)
+		return nil, err
+	}
+	diagramParticipants.observe(float64(len(sd.GetOrderedNodes())), endpoint)
+	diagramMessages.observe(float64(len(sd.Messages())), endpoint)
+	return sd, nil
+}
+
+// timeRender runs render and records its duration for the format
+func timeRender(format string, render func() error) error {
+	start := time.Now()
+	err := render()
+	renderDuration.observe(time.Since(start).Seconds(), format)
+	return err
+}
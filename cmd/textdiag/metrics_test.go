@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsExposition(t *testing.T) {
+	c := newCounterVec("test_total", "Test counter.", "path")
+	c.inc(`/b`)
+	c.inc(`/a"\` + "\n")
+	c.inc(`/b`)
+	h := newHistogramVec("test_seconds", "Test histogram.", []float64{.5, 1}, "format")
+	h.observe(.25, "svg")
+	h.observe(.75, "svg")
+	h.observe(2, "svg")
+
+	var b strings.Builder
+	c.write(&b)
+	h.write(&b)
+	want := `# HELP test_total Test counter.
+# TYPE test_total counter
+test_total{path="/a\"\\\n"} 1
+test_total{path="/b"} 2
+# HELP test_seconds Test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{format="svg",le="0.5"} 1
+test_seconds_bucket{format="svg",le="1"} 2
+test_seconds_bucket{format="svg",le="+Inf"} 3
+test_seconds_sum{format="svg"} 3
+test_seconds_count{format="svg"} 3
+`
+	if b.String() != want {
+		t.Errorf("TestMetricsExposition => got:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	mux := newWebMux(newMemoryStore(1<<20), nil)
+	count := func(c *counterVec, labels ...string) float64 {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.values[labelKey(labels)]
+	}
+	requests, parseErrors := count(httpRequests, "/api/v1/render", "400"), count(parseErrorCount, "render")
+	participants := diagramParticipants.series[labelKey([]string{"render"})]
+	var observed uint64
+	if participants != nil {
+		observed = participants.count
+	}
+
+	for _, source := range []string{"a->b:x\nb->c:y", "a->b:x\nbad"} {
+		req := httptest.NewRequest("POST", "/api/v1/render", strings.NewReader(source))
+		req.Header.Set("Content-Type", "text/plain")
+		mux.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if got := count(httpRequests, "/api/v1/render", "400"); got != requests+1 {
+		t.Errorf("TestMetricsEndpoint => got %v requests with status 400, want %v", got, requests+1)
+	}
+	if got := count(parseErrorCount, "render"); got != parseErrors+1 {
+		t.Errorf("TestMetricsEndpoint => got %v parse errors, want %v", got, parseErrors+1)
+	}
+	if got := diagramParticipants.series[labelKey([]string{"render"})].count; got != observed+1 {
+		t.Errorf("TestMetricsEndpoint => got %d observed diagrams, want %d", got, observed+1)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Header().Get("Content-Type") != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("TestMetricsEndpoint => got content type %q", w.Header().Get("Content-Type"))
+	}
+	for _, want := range []string{
+		`textdiag_http_requests_total{route="/api/v1/render",code="200"} `,
+		`textdiag_render_duration_seconds_count{format="text"} `,
+		`textdiag_parse_errors_total{endpoint="render"} `,
+		`textdiag_diagram_participants_bucket{endpoint="render",le="3"} `,
+		`textdiag_diagram_messages_sum{endpoint="render"} `,
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("TestMetricsEndpoint => /metrics does not contain %q:\n%s", want, w.Body.String())
+		}
+	}
+}
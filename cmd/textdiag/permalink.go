@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// permalinkPrefix is the path of stored diagrams, /d/{id}
+const permalinkPrefix = "/d/"
+
+// apiSaveResponse is the response of the save API
+type apiSaveResponse struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+}
+
+// saveAPI handles POST /api/v1/diagrams. It stores a valid diagram under the
+// hash of its source and returns the permalink.
+func saveAPI(store diagramStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeAPIError(w, http.StatusMethodNotAllowed, "Only POST is allowed.")
+			return
+		}
+		source, ok := readSource(w, r)
+		if !ok {
+			return
+		}
+		if _, err := parseObserved("save", source); err != nil {
+			writeJSON(w, http.StatusBadRequest, parseErrors(err))
+			return
+		}
+		id := diagramID([]byte(source))
+		if err := store.Save(id, []byte(source)); err != nil {
+			logJSON(r.Context(), "error", "saving diagram failed", "id", id, "error", err)
+			writeAPIError(w, http.StatusInternalServerError, "Saving the diagram failed.")
+			return
+		}
+		w.Header().Set("Location", permalinkPrefix+id)
+		writeJSON(w, http.StatusCreated, apiSaveResponse{id, permalinkPrefix + id})
+	}
+}
+
+// permalinkOutputs are the rendered diagrams of a permalink by extension
+var permalinkOutputs = map[string]struct {
+	contentType string
+	format      string
+}{
+	".txt": {"text/plain; charset=utf-8", "text"},
+	".svg": {"image/svg+xml; charset=utf-8", "svg"},
+}
+
+// permalink serves a stored diagram: /d/{id} is the web page editing it,
+// /d/{id}.sd the source, and /d/{id}.txt and /d/{id}.svg the rendered diagram
+func permalink(store diagramStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		name := strings.TrimPrefix(r.URL.Path, permalinkPrefix)
+		ext := path.Ext(name)
+		id := strings.TrimSuffix(name, ext)
+		output, isOutput := permalinkOutputs[ext]
+		if !diagramIDPattern.MatchString(id) || ext != "" && ext != ".sd" && !isOutput {
+			http.NotFound(w, r)
+			return
+		}
+		source, err := store.Load(id)
+		if err == errDiagramNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			logJSON(r.Context(), "error", "loading diagram failed", "id", id, "error", err)
+			http.Error(w, "loading the diagram failed", http.StatusInternalServerError)
+			return
+		}
+		if ext == "" {
+			writePage(w, r)
+			return
+		}
+
+		// the content of an id never changes
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		if !isOutput {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write(source)
+			return
+		}
+		sd, err := parseObserved("permalink", string(source))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", output.contentType)
+		if err := timeRender(output.format, func() error { return formats[output.format](w, sd) }); err != nil {
+			logJSON(r.Context(), "error", "writing diagram failed", "id", id, "error", err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDiagramStores(t *testing.T) {
+	dir := t.TempDir()
+	files, err := newFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores := map[string]diagramStore{"memory": newMemoryStore(1 << 20), "file": files}
+	for name, store := range stores {
+		id := diagramID([]byte("a->b:x"))
+		if _, err := store.Load(id); err != errDiagramNotFound {
+			t.Errorf("TestDiagramStores => %s: Load before Save got %v, want %v", name, err, errDiagramNotFound)
+		}
+		if err := store.Save(id, []byte("a->b:x")); err != nil {
+			t.Fatalf("TestDiagramStores => %s: Save got %v", name, err)
+		}
+		if got, err := store.Load(id); err != nil || string(got) != "a->b:x" {
+			t.Errorf("TestDiagramStores => %s: Load got %q, %v, want %q", name, got, err, "a->b:x")
+		}
+		if _, err := store.Load("../" + id); err != errDiagramNotFound {
+			t.Errorf("TestDiagramStores => %s: Load of an invalid id got %v, want %v", name, err, errDiagramNotFound)
+		}
+	}
+	// saved files are kept when the server restarts
+	reopened, err := newFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := reopened.Load(diagramID([]byte("a->b:x"))); err != nil || string(got) != "a->b:x" {
+		t.Errorf("TestDiagramStores => reopened file store got %q, %v", got, err)
+	}
+}
+
+func TestMemoryStoreEviction(t *testing.T) {
+	store := newMemoryStore(10)
+	store.Save("a", []byte("aaaa"))
+	store.Save("b", []byte("bbbb"))
+	store.Load("a")
+	// b is the least recently used
+	store.Save("c", []byte("cccc"))
+	for id, want := range map[string]error{"a": nil, "b": errDiagramNotFound, "c": nil} {
+		if _, err := store.Load(id); err != want {
+			t.Errorf("TestMemoryStoreEviction => Load(%q) got %v, want %v", id, err, want)
+		}
+	}
+	// saving a diagram again does not count it twice
+	store.Save("c", []byte("cccc"))
+	if store.size != 8 {
+		t.Errorf("TestMemoryStoreEviction => got size %d, want 8", store.size)
+	}
+	if err := store.Save("d", []byte("ddddddddddd")); err == nil {
+		t.Errorf("TestMemoryStoreEviction => saving a diagram larger than the store got no error")
+	}
+}
+
+func TestPermalinks(t *testing.T) {
+	mux := newWebMux(newMemoryStore(1<<20), nil)
+	source := "a->b:x\n"
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/diagrams", strings.NewReader(source)))
+	var saved apiSaveResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &saved); err != nil || w.Code != 201 {
+		t.Fatalf("TestPermalinks => save got %d %s", w.Code, w.Body)
+	}
+	if saved.ID != diagramID([]byte(source)) || saved.URL != "/d/"+saved.ID || w.Header().Get("Location") != saved.URL {
+		t.Errorf("TestPermalinks => save got %+v, Location %q", saved, w.Header().Get("Location"))
+	}
+
+	tests := []struct {
+		method   string
+		path     string
+		body     string
+		status   int
+		wantType string
+		want     string
+	}{
+		{"GET", saved.URL, "", 200, "text/html; charset=utf-8", "shareDiagram()"},
+		{"GET", saved.URL + ".sd", "", 200, "text/plain; charset=utf-8", source},
+		{"GET", saved.URL + ".txt", "", 200, "text/plain; charset=utf-8", "┤ x ├"},
+		{"HEAD", saved.URL + ".svg", "", 200, "image/svg+xml; charset=utf-8", ""},
+		{"GET", saved.URL + ".svg", "", 200, "image/svg+xml; charset=utf-8", "<svg"},
+		{"GET", saved.URL + ".png", "", 404, "text/plain; charset=utf-8", "404 page not found"},
+		{"GET", "/d/AAAAAAAAAAAAAAAA.txt", "", 404, "text/plain; charset=utf-8", "404 page not found"},
+		{"POST", saved.URL, "", 405, "text/plain; charset=utf-8", "method not allowed"},
+		// the same source gets the same id
+		{"POST", "/api/v1/diagrams", `{"source": "a->b:x\n"}`, 201, "application/json; charset=utf-8", `"id":"` + saved.ID + `"`},
+		{"POST", "/api/v1/diagrams", "a->b:x\nbad", 400, "application/json; charset=utf-8", `{"errors":[{"line":2,"message":"Syntax error."}]}`},
+		{"GET", "/api/v1/diagrams", "", 405, "application/json; charset=utf-8", "Only POST is allowed."},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		if strings.HasPrefix(test.body, "{") {
+			r.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != test.status || w.Header().Get("Content-Type") != test.wantType || !strings.Contains(w.Body.String(), test.want) {
+			t.Errorf("TestPermalinks => %s %s got %d %s:\n%.200s\nwant %d %s containing %q",
+				test.method, test.path, w.Code, w.Header().Get("Content-Type"), w.Body.String(), test.status, test.wantType, test.want)
+		}
+	}
+}
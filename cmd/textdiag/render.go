@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Laugusti/sequencediagram"
+	"github.com/Laugusti/sequencediagram/textdiagram"
+)
+
+// formats are the output formats of render, by name and file extension
+var formats = map[string]func(w io.Writer, sd *sequencediagram.Diagram) error{
+	"text": func(w io.Writer, sd *sequencediagram.Diagram) error {
+		if _, err := io.Copy(w, textdiagram.Encode(sd)); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintln(w)
+		return err
+	},
+	"svg": func(w io.Writer, sd *sequencediagram.Diagram) error {
+		_, err := io.Copy(w, textdiagram.EncodeSVG(sd))
+		return err
+	},
+	"json": func(w io.Writer, sd *sequencediagram.Diagram) error {
+		b, err := json.MarshalIndent(sd, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	},
+}
+
+var formatExtensions = map[string]string{".txt": "text", ".svg": "svg", ".json": "json"}
+
+// render writes a single sequence diagram file in the chosen format
+func render(args []string) {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	input := fs.String("i", "-", "sequence diagram file, - for stdin")
+	output := fs.String("o", "-", "output file, - for stdout")
+	format := fs.String("format", "", "output format: text, svg or json (default from the output file extension, or text)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s render [-i file] [-o file] [-format text|svg|json]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err := renderFile(*input, *output, *format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// renderFile parses the whole input and writes the output only if there are no errors
+func renderFile(input, output, format string) error {
+	if format == "" {
+		format = formatExtensions[strings.ToLower(filepath.Ext(output))]
+		if format == "" {
+			format = "text"
+		}
+	}
+	encode, ok := formats[format]
+	if !ok {
+		return fmt.Errorf("unknown format %q", format)
+	}
+
+	name := input
+	var text []byte
+	var err error
+	if input == "-" {
+		name = "<stdin>"
+		text, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		text, err = ioutil.ReadFile(input)
+	}
+	if err != nil {
+		return err
+	}
+	sd, err := parseSource(string(text))
+	if err != nil {
+		return positionalError(name, err)
+	}
+
+	var b bytes.Buffer
+	if err := encode(&b, sd); err != nil {
+		return err
+	}
+	if output == "-" {
+		_, err = b.WriteTo(os.Stdout)
+		return err
+	}
+	return ioutil.WriteFile(output, b.Bytes(), 0644)
+}
+
+// parseSource parses the text of a sequence diagram file, which usually ends
+// with a newline
+func parseSource(text string) (*sequencediagram.Diagram, error) {
+	return sequencediagram.ParseFromText(strings.TrimRight(strings.Replace(text, "\r\n", "\n", -1), "\n"))
+}
+
+var lineErrorPattern = regexp.MustCompile(`^Line ([0-9]+): (.*)$`)
+
+// positionalError prefixes each "Line N: message" error with the file name,
+// as "file:N: message"
+func positionalError(name string, err error) error {
+	return positionalErrorAt(name, 0, err)
+}
+
+// positionalErrorAt is like positionalError for a sequence diagram that starts
+// after line offset of the file
+func positionalErrorAt(name string, offset int, err error) error {
+	lines := strings.Split(err.Error(), "\n")
+	for i, line := range lines {
+		if match := lineErrorPattern.FindStringSubmatch(line); match != nil {
+			n, _ := strconv.Atoi(match[1])
+			lines[i] = name + ":" + strconv.Itoa(n+offset) + ": " + match[2]
+		} else {
+			lines[i] = name + ": " + line
+		}
+	}
+	return fmt.Errorf("%s", strings.Join(lines, "\n"))
+}
+
+// repl reads sequence diagram lines from stdin and prints the diagram after each valid line
+func repl(args []string) {
+	fs := flag.NewFlagSet("repl", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s repl\n", os.Args[0])
+	}
+	fs.Parse(args)
+	commandLine()
+}
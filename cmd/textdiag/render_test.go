@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPositionalError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{errors.New("Line 3: Syntax error."), "flow.sd:3: Syntax error."},
+		{errors.New("Line 2: Only participants can be declared in a box.\nLine 1: Box is never ended."),
+			"flow.sd:2: Only participants can be declared in a box.\nflow.sd:1: Box is never ended."},
+		{errors.New("unexpected EOF"), "flow.sd: unexpected EOF"},
+	}
+	for _, test := range tests {
+		if got := positionalError("flow.sd", test.err).Error(); got != test.want {
+			t.Errorf("TestPositionalError => input: %q, got %q, want %q", test.err, got, test.want)
+		}
+	}
+}
+
+func TestRenderFile(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "flow.sd")
+	if err := ioutil.WriteFile(input, []byte("a->b:x\r\nb-->a:y\n\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		output, format string
+		prefix         string
+	}{
+		{"flow.txt", "", "┌───┐"},
+		{"flow.svg", "", "<svg "},
+		{"flow.json", "", "{\n  \"participants\""},
+		{"flow.out", "json", "{"},
+		{"flow.out", "", "┌───┐"},
+	}
+	for _, test := range tests {
+		output := filepath.Join(dir, test.output)
+		if err := renderFile(input, output, test.format); err != nil {
+			t.Errorf("TestRenderFile => output %s: got error: %v", test.output, err)
+			continue
+		}
+		b, _ := ioutil.ReadFile(output)
+		if !strings.HasPrefix(string(b), test.prefix) {
+			t.Errorf("TestRenderFile => output %s: got %q, want prefix %q", test.output, b, test.prefix)
+		}
+	}
+
+	bad := filepath.Join(dir, "bad.sd")
+	ioutil.WriteFile(bad, []byte("a->b:x\nbad\n"), 0644)
+	output := filepath.Join(dir, "bad.txt")
+	if err := renderFile(bad, output, ""); err == nil || err.Error() != bad+":2: Syntax error." {
+		t.Errorf("TestRenderFile => got error %v, want positional syntax error", err)
+	}
+	if _, err := ioutil.ReadFile(output); err == nil {
+		t.Errorf("TestRenderFile => expected no output for invalid input")
+	}
+	if err := renderFile(input, "-", "png"); err == nil {
+		t.Errorf("TestRenderFile => expected error for unknown format")
+	}
+}
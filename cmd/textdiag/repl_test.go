@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestREPL(t *testing.T) {
+	tests := []struct {
+		input    string
+		diagrams int
+		errors   string
+	}{
+		{"a->b:x\nbad\nb-->a:y", 2, "Line 2: Syntax error.\n"},
+		// blocks are drawn once they are ended
+		{"box \"B\"\nparticipant a\nend box\na->b:x", 2, ""},
+		{"a->b:x\nloop l\nb-->a:y\nloop m\na->a:z\nend\nend", 2, ""},
+		{"box \"B\"\na->b:x\nparticipant a\nend box", 1, "Line 2: Only participants can be declared in a box.\n"},
+		{"end", 0, "Line 1: End without loop.\n"},
+	}
+	for _, test := range tests {
+		var out, errs bytes.Buffer
+		runREPL(strings.NewReader(test.input), &out, log.New(&errs, "", 0))
+		if got := strings.Count(out.String(), "\n\n┌"); got != test.diagrams || errs.String() != test.errors {
+			t.Errorf("TestREPL => input %q got %d diagrams and errors %q, want %d and %q", test.input, got, errs.String(), test.diagrams, test.errors)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+//go:embed textSD.html
+var ui embed.FS
+
+// web server flags
+var (
+	addr            = flag.String("addr", ":8080", "listen address of the web server")
+	tlsCert         = flag.String("tls-cert", "", "TLS certificate file, serves HTTPS together with -tls-key")
+	tlsKey          = flag.String("tls-key", "", "TLS private key file")
+	readTimeout     = flag.Duration("read-timeout", 10*time.Second, "maximum duration for reading a request")
+	writeTimeout    = flag.Duration("write-timeout", 30*time.Second, "maximum duration for writing a response")
+	idleTimeout     = flag.Duration("idle-timeout", 2*time.Minute, "maximum duration a keep-alive connection waits for the next request")
+	shutdownTimeout = flag.Duration("shutdown-timeout", 10*time.Second, "maximum duration to wait for requests to finish on shutdown")
+	storeDir        = flag.String("store-dir", "", "directory of the diagrams saved for permalinks (default in memory until the server stops)")
+	memoryStoreSize = flag.Int("memory-store-size", 64<<20, "maximum bytes of diagrams kept in memory without -store-dir, the least recently used are dropped")
+	allowedOrigins  = flag.String("allowed-origins", "", "comma separated origins of other sites allowed to join collaboration rooms, like https://wiki.example.com")
+)
+
+// webServer serves the web page and the API until SIGINT or SIGTERM
+func webServer() {
+	if (*tlsCert == "") != (*tlsKey == "") {
+		fatal(errors.New("-tls-cert and -tls-key must be set together"))
+	}
+	var store diagramStore = newMemoryStore(*memoryStoreSize)
+	if *storeDir != "" {
+		var err error
+		if store, err = newFileStore(*storeDir); err != nil {
+			fatal(err)
+		}
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fatal(err)
+	}
+	srv := &http.Server{
+		Handler:      newWebMux(store, splitList(*allowedOrigins)),
+		ErrorLog:     log.New(logWriter{}, "", 0),
+		ReadTimeout:  *readTimeout,
+		WriteTimeout: *writeTimeout,
+		IdleTimeout:  *idleTimeout,
+	}
+	logJSON(ctx, "info", "listening", "addr", ln.Addr().String(), "tls", *tlsCert != "")
+	if err := runServer(ctx, srv, ln, *tlsCert, *tlsKey, *shutdownTimeout); err != nil {
+		fatal(err)
+	}
+	logJSON(ctx, "info", "server stopped")
+}
+
+// splitList returns the non-empty items of a comma separated list
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// fatal logs the error that stops the web server and exits
+func fatal(err error) {
+	logJSON(context.Background(), "error", "server failed", "error", err)
+	os.Exit(1)
+}
+
+// runServer serves on ln until ctx is done, then waits up to shutdownTimeout
+// for the active requests to finish
+func runServer(ctx context.Context, srv *http.Server, ln net.Listener, certFile, keyFile string, shutdownTimeout time.Duration) error {
+	errc := make(chan error, 1)
+	go func() {
+		if certFile != "" {
+			errc <- srv.ServeTLS(ln, certFile, keyFile)
+		} else {
+			errc <- srv.Serve(ln)
+		}
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// newWebMux serves the web page, the API, the permalinks of the diagrams in
+// store, the collaboration rooms, the health check and the metrics. Every
+// request is counted and logged. Pages of the same host and of origins can
+// join the collaboration rooms.
+func newWebMux(store diagramStore, origins []string) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/favicon.ico", http.NotFoundHandler())
+	mux.HandleFunc("/", servePage)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/api/v1/render", renderAPI)
+	mux.HandleFunc("/api/v1/diagrams", saveAPI(store))
+	mux.HandleFunc(permalinkPrefix, permalink(store))
+	mux.HandleFunc(collabPrefix, newCollabHub(origins).collab)
+	mux.HandleFunc("/metrics", serveMetrics)
+	return observe(mux)
+}
+
+// servePage serves the embedded web page at /
+func servePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	writePage(w, r)
+}
+
+// writePage writes the embedded web page
+func writePage(w http.ResponseWriter, r *http.Request) {
+	page, err := ui.ReadFile("textSD.html")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.ServeContent(w, r, "textSD.html", time.Time{}, bytes.NewReader(page))
+}
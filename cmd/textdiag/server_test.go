@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebMux(t *testing.T) {
+	tests := []struct {
+		path     string
+		status   int
+		wantType string
+		want     string
+	}{
+		{"/", 200, "text/html; charset=utf-8", `xhr.open("POST", "/api/v1/render")`},
+		{"/healthz", 200, "text/plain; charset=utf-8", "ok\n"},
+		{"/textSD.html", 404, "text/plain; charset=utf-8", "404 page not found"},
+	}
+	// the page is embedded so the working directory does not matter
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(t.TempDir())
+	mux := newWebMux(newMemoryStore(1<<20), nil)
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+		if w.Code != test.status || w.Header().Get("Content-Type") != test.wantType || !strings.Contains(w.Body.String(), test.want) {
+			t.Errorf("TestWebMux => GET %s got %d %s:\n%.100s\nwant %d %s containing %q",
+				test.path, w.Code, w.Header().Get("Content-Type"), w.Body.String(), test.status, test.wantType, test.want)
+		}
+	}
+}
+
+func TestRunServerGracefulShutdown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started, release := make(chan bool), make(chan bool)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- true
+		<-release
+		w.Write([]byte("done"))
+	})}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() { stopped <- runServer(ctx, srv, ln, "", "", 5*time.Second) }()
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+	<-started
+	cancel()
+	// the server waits for the active request
+	select {
+	case err := <-stopped:
+		t.Fatalf("TestRunServerGracefulShutdown => server stopped before the request finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if got := <-body; got != "done" {
+		t.Errorf("TestRunServerGracefulShutdown => got response %q, want %q", got, "done")
+	}
+	if err := <-stopped; err != nil {
+		t.Errorf("TestRunServerGracefulShutdown => got error %v", err)
+	}
+}
+
+func TestRunServerTLS(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: newWebMux(newMemoryStore(1<<20), nil)}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() { stopped <- runServer(ctx, srv, ln, certFile, keyFile, time.Second) }()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := client.Get("https://" + ln.Addr().String() + "/healthz")
+	if err != nil {
+		t.Fatalf("TestRunServerTLS => %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 || resp.TLS == nil {
+		t.Errorf("TestRunServerTLS => got status %d, TLS %v", resp.StatusCode, resp.TLS != nil)
+	}
+	cancel()
+	if err := <-stopped; err != nil {
+		t.Errorf("TestRunServerTLS => got error %v", err)
+	}
+}
+
+// writeTestCertificate writes a self-signed certificate for 127.0.0.1
+func writeTestCertificate(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
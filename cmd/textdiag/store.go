@@ -0,0 +1,132 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// errDiagramNotFound is returned by a diagramStore for unknown ids
+var errDiagramNotFound = errors.New("diagram not found")
+
+// diagramStore keeps diagram sources by id for permalinks
+type diagramStore interface {
+	Save(id string, source []byte) error
+	Load(id string) ([]byte, error)
+}
+
+var diagramIDPattern = regexp.MustCompile("^[A-Za-z0-9_-]{16}$")
+
+// diagramID is the content hash id of a diagram source, the same source always
+// gets the same id
+func diagramID(source []byte) string {
+	sum := sha256.Sum256(source)
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+// memoryStore keeps diagrams until the server stops, up to maxBytes of
+// sources. The least recently used diagrams are dropped to make room.
+type memoryStore struct {
+	maxBytes int
+	mu       sync.Mutex
+	size     int
+	order    *list.List // of *memoryDiagram, most recently used first
+	diagrams map[string]*list.Element
+}
+
+type memoryDiagram struct {
+	id     string
+	source []byte
+}
+
+func newMemoryStore(maxBytes int) *memoryStore {
+	return &memoryStore{maxBytes: maxBytes, order: list.New(), diagrams: make(map[string]*list.Element)}
+}
+
+func (s *memoryStore) Save(id string, source []byte) error {
+	if len(source) > s.maxBytes {
+		return errors.New("diagram larger than the memory store")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.diagrams[id]; ok {
+		s.remove(e)
+	}
+	for s.size+len(source) > s.maxBytes {
+		s.remove(s.order.Back())
+	}
+	s.diagrams[id] = s.order.PushFront(&memoryDiagram{id, append([]byte(nil), source...)})
+	s.size += len(source)
+	return nil
+}
+
+func (s *memoryStore) remove(e *list.Element) {
+	d := s.order.Remove(e).(*memoryDiagram)
+	delete(s.diagrams, d.id)
+	s.size -= len(d.source)
+}
+
+func (s *memoryStore) Load(id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.diagrams[id]
+	if !ok {
+		return nil, errDiagramNotFound
+	}
+	s.order.MoveToFront(e)
+	return e.Value.(*memoryDiagram).source, nil
+}
+
+// fileStore keeps each diagram in a file named after its id in a directory
+type fileStore struct {
+	dir string
+}
+
+func newFileStore(dir string) (*fileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &fileStore{dir}, nil
+}
+
+func (s *fileStore) path(id string) string {
+	return filepath.Join(s.dir, id+".sd")
+}
+
+// Save writes to a temporary file first so a diagram is never read half written
+func (s *fileStore) Save(id string, source []byte) error {
+	if !diagramIDPattern.MatchString(id) {
+		return errors.New("invalid diagram id")
+	}
+	f, err := ioutil.TempFile(s.dir, id+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(source); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), s.path(id))
+}
+
+func (s *fileStore) Load(id string) ([]byte, error) {
+	if !diagramIDPattern.MatchString(id) {
+		return nil, errDiagramNotFound
+	}
+	source, err := ioutil.ReadFile(s.path(id))
+	if os.IsNotExist(err) {
+		return nil, errDiagramNotFound
+	}
+	return source, err
+}
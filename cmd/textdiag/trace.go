@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/Laugusti/sequencediagram"
+	"github.com/Laugusti/sequencediagram/textdiagram"
+)
+
+// importTrace prints the text diagram of each OTLP or Jaeger JSON trace file
+func importTrace(args []string) {
+	fs := flag.NewFlagSet("import-trace", flag.ExitOnError)
+	source := fs.Bool("source", false, "print the sequence diagram instead of the text diagram")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s import-trace [-source] file...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	for _, filename := range fs.Args() {
+		sd, err := sequencediagram.ReadTraceFile(filename)
+		if err != nil {
+			log.Fatalf("failed to import trace %s: %v", filename, err)
+		}
+		if *source {
+			fmt.Println(sd)
+			continue
+		}
+		io.Copy(os.Stdout, textdiagram.Encode(sd))
+		fmt.Println()
+	}
+}
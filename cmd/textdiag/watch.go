@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Laugusti/sequencediagram"
+)
+
+// clearScreen moves the cursor to the top left and clears the terminal
+const clearScreen = "\033[H\033[2J"
+
+// watchedFile is a sequence diagram file and the state it was last rendered in
+type watchedFile struct {
+	name    string
+	modTime time.Time
+	size    int64
+	err     error
+}
+
+// changed updates the state of the file and reports whether it changed
+func (f *watchedFile) changed() bool {
+	info, err := os.Stat(f.name)
+	if err != nil {
+		changed := f.err == nil || f.err.Error() != err.Error()
+		f.err = err
+		return changed
+	}
+	changed := f.err != nil || !info.ModTime().Equal(f.modTime) || info.Size() != f.size
+	f.modTime, f.size, f.err = info.ModTime(), info.Size(), nil
+	return changed
+}
+
+// watch re-renders the sequence diagram files every time one of them changes
+func watch(args []string) {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	interval := fs.Duration("interval", 500*time.Millisecond, "how often the files are checked for changes")
+	write := fs.Bool("write", false, "also write the output next to each file, with the extension of the format")
+	format := fs.String("format", "text", "format of the written output: text, svg or json")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s watch [-interval duration] [-write] [-format text|svg|json] file...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if _, ok := formats[*format]; !ok {
+		fmt.Fprintf(os.Stderr, "unknown format %q\n", *format)
+		os.Exit(2)
+	}
+
+	var files []*watchedFile
+	for _, name := range fs.Args() {
+		files = append(files, &watchedFile{name: name})
+	}
+	for {
+		var changed bool
+		for _, f := range files {
+			// check every file so each one's state is up to date
+			if f.changed() {
+				changed = true
+			}
+		}
+		if changed {
+			var b bytes.Buffer
+			b.WriteString(clearScreen)
+			renderWatched(&b, files, *write, *format)
+			b.WriteTo(os.Stdout)
+		}
+		time.Sleep(*interval)
+	}
+}
+
+// renderWatched writes the text diagram of each file, or its errors, to w and
+// writes the output next to each valid file if write is set
+func renderWatched(w io.Writer, files []*watchedFile, write bool, format string) {
+	for i, f := range files {
+		if len(files) > 1 {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "%s\n\n", f.name)
+		}
+		text, err := ioutil.ReadFile(f.name)
+		if err != nil {
+			fmt.Fprintln(w, err)
+			continue
+		}
+		sd, err := parseSource(string(text))
+		if err != nil {
+			fmt.Fprintln(w, positionalError(f.name, err))
+			continue
+		}
+		formats["text"](w, sd)
+		if !write {
+			continue
+		}
+		if err := writeOutput(f.name, format, sd); err != nil {
+			fmt.Fprintln(w, err)
+		}
+	}
+}
+
+// writeOutput writes the diagram of the source file next to it in the format,
+// it never overwrites the source
+func writeOutput(name, format string, sd *sequencediagram.Diagram) error {
+	output := outputName(name, format)
+	if sameFile(name, output) {
+		return fmt.Errorf("%s: output would overwrite the source", name)
+	}
+	var b bytes.Buffer
+	if err := formats[format](&b, sd); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	return ioutil.WriteFile(output, b.Bytes(), 0644)
+}
+
+// outputName replaces the extension of the file with the one of the format,
+// or appends it when the file already has that extension
+func outputName(name, format string) string {
+	ext := ".txt"
+	for e, f := range formatExtensions {
+		if f == format {
+			ext = e
+		}
+	}
+	if strings.EqualFold(filepath.Ext(name), ext) {
+		return name + ext
+	}
+	return strings.TrimSuffix(name, filepath.Ext(name)) + ext
+}
+
+// sameFile reports whether both names are the same file, including through
+// links or a case-insensitive file system
+func sameFile(a, b string) bool {
+	if filepath.Clean(a) == filepath.Clean(b) {
+		return true
+	}
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	infoB, err := os.Stat(b)
+	return err == nil && os.SameFile(infoA, infoB)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Laugusti/sequencediagram"
+)
+
+func TestWatchedFileChanged(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "flow.sd")
+	f := &watchedFile{name: name}
+	steps := []struct {
+		update func()
+		want   bool
+	}{
+		{func() {}, true}, // missing file is reported once
+		{func() {}, false},
+		{func() { ioutil.WriteFile(name, []byte("a->b:x"), 0644) }, true},
+		{func() {}, false},
+		{func() { os.Chtimes(name, time.Now(), time.Now().Add(time.Minute)) }, true},
+		{func() { ioutil.WriteFile(name, []byte("a->b:xy"), 0644) }, true},
+		{func() { os.Remove(name) }, true},
+	}
+	for i, step := range steps {
+		step.update()
+		if got := f.changed(); got != step.want {
+			t.Errorf("TestWatchedFileChanged => step %d: got %v, want %v", i, got, step.want)
+		}
+	}
+}
+
+func TestRenderWatched(t *testing.T) {
+	dir := t.TempDir()
+	good, bad := filepath.Join(dir, "good.sd"), filepath.Join(dir, "bad.sd")
+	ioutil.WriteFile(good, []byte("a->b:x\n"), 0644)
+	ioutil.WriteFile(bad, []byte("a->b:x\nbad\n"), 0644)
+
+	var b bytes.Buffer
+	renderWatched(&b, []*watchedFile{{name: good}, {name: bad}}, true, "svg")
+	out := b.String()
+	if !strings.HasPrefix(out, good+"\n\n┌───┐") {
+		t.Errorf("TestRenderWatched => expected the text diagram of %s first, got:\n%s", good, out)
+	}
+	if !strings.HasSuffix(out, "\n"+bad+"\n\n"+bad+":2: Syntax error.\n") {
+		t.Errorf("TestRenderWatched => expected the error of %s last, got:\n%s", bad, out)
+	}
+	if svg, err := ioutil.ReadFile(filepath.Join(dir, "good.svg")); err != nil || !strings.HasPrefix(string(svg), "<svg ") {
+		t.Errorf("TestRenderWatched => expected good.svg to be written, got %q, %v", svg, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "bad.svg")); err == nil {
+		t.Errorf("TestRenderWatched => expected no output for bad.sd")
+	}
+}
+
+func TestWatchWriteKeepsSource(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "flow_sd.txt")
+	ioutil.WriteFile(source, []byte("a->b:x\n"), 0644)
+
+	var b bytes.Buffer
+	renderWatched(&b, []*watchedFile{{name: source}}, true, "text")
+	if text, _ := ioutil.ReadFile(source); string(text) != "a->b:x\n" {
+		t.Errorf("TestWatchWriteKeepsSource => source was overwritten with:\n%s", text)
+	}
+	if text, err := ioutil.ReadFile(source + ".txt"); err != nil || !strings.HasPrefix(string(text), "┌───┐") {
+		t.Errorf("TestWatchWriteKeepsSource => expected flow_sd.txt.txt to be written, got %q, %v", text, err)
+	}
+
+	// an output name that links to the source is refused
+	link := filepath.Join(dir, "link.sd")
+	ioutil.WriteFile(link, []byte("a->b:y\n"), 0644)
+	os.Symlink(link, filepath.Join(dir, "link.txt"))
+	b.Reset()
+	renderWatched(&b, []*watchedFile{{name: link}}, true, "text")
+	if text, _ := ioutil.ReadFile(link); string(text) != "a->b:y\n" {
+		t.Errorf("TestWatchWriteKeepsSource => source was overwritten through a link with:\n%s", text)
+	}
+	if !strings.HasSuffix(b.String(), link+": output would overwrite the source\n") {
+		t.Errorf("TestWatchWriteKeepsSource => expected an error for link.sd, got:\n%s", b.String())
+	}
+}
+
+func TestWriteOutputEncodeError(t *testing.T) {
+	formats["failing"] = func(io.Writer, *sequencediagram.Diagram) error { return errors.New("encode failed") }
+	defer delete(formats, "failing")
+	source := filepath.Join(t.TempDir(), "flow.sd")
+	sd, _ := sequencediagram.ParseFromText("a->b:x")
+	err := writeOutput(source, "failing", sd)
+	if err == nil || err.Error() != source+": encode failed" {
+		t.Errorf("TestWriteOutputEncodeError => got error %v, want %q", err, source+": encode failed")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(source), "flow.txt")); !os.IsNotExist(err) {
+		t.Errorf("TestWriteOutputEncodeError => expected no output to be written, got %v", err)
+	}
+}
+
+func TestOutputName(t *testing.T) {
+	tests := []struct {
+		name, format, want string
+	}{
+		{"flow.sd", "text", "flow.txt"},
+		{"flow.sd", "svg", "flow.svg"},
+		{"flow_sd.txt", "text", "flow_sd.txt.txt"},
+		{"flow_sd.TXT", "text", "flow_sd.TXT.txt"},
+		{"flow_sd.txt", "json", "flow_sd.json"},
+		{"flow", "text", "flow.txt"},
+	}
+	for _, test := range tests {
+		if got := outputName(test.name, test.format); got != test.want {
+			t.Errorf("TestOutputName => %s as %s got %s, want %s", test.name, test.format, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// websocketGUID is appended to the client key of a WebSocket handshake (RFC 6455)
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// WebSocket close codes
+const (
+	wsCloseNormal        = 1000
+	wsCloseProtocolError = 1002
+	wsCloseUnsupported   = 1003
+	wsCloseTooBig        = 1009
+)
+
+// wsWriteTimeout bounds the time to write a frame to a client
+const wsWriteTimeout = 10 * time.Second
+
+var errWebSocketClosed = errors.New("websocket closed")
+
+// wsConn is the server side of a WebSocket connection that exchanges text
+// messages. Messages are read by one goroutine and can be written by several.
+type wsConn struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	mu     sync.Mutex
+	closed bool
+}
+
+// upgradeWebSocket completes the WebSocket handshake of r, or writes an error
+// response. Browsers can only connect from a page of the same host or of one of
+// the origins, so other sites cannot use the connection of a visitor.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request, origins []string) (*wsConn, error) {
+	if r.Method != http.MethodGet || !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		w.Header().Set("Upgrade", "websocket")
+		http.Error(w, "websocket upgrade required", http.StatusUpgradeRequired)
+		return nil, errors.New("not a websocket handshake")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Header.Get("Sec-WebSocket-Version") != "13" || key == "" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusBadRequest)
+		return nil, errors.New("unsupported websocket version")
+	}
+	if !originAllowed(r, origins) {
+		http.Error(w, "websocket origin not allowed", http.StatusForbidden)
+		return nil, fmt.Errorf("origin %q not allowed", r.Header.Get("Origin"))
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, errors.New("response does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	// the deadlines of the server timeouts do not apply to the websocket
+	conn.SetDeadline(time.Time{})
+	_, err = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + websocketAccept(key) + "\r\n\r\n")
+	if err == nil {
+		err = rw.Flush()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, r: rw.Reader}, nil
+}
+
+// headerContains reports whether a comma separated header has the token
+func headerContains(h http.Header, name, token string) bool {
+	for _, value := range h[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// originAllowed reports whether the Origin of the request is the host of the
+// request or one of origins. Clients other than browsers may omit the Origin.
+func originAllowed(r *http.Request, origins []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range origins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func websocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// ReadMessage returns the next text message of at most maxRequestBytes. Pings
+// are answered while waiting, and a close from the client is returned as io.EOF.
+func (c *wsConn) ReadMessage() (string, error) {
+	var message []byte
+	started := false
+	for {
+		fin, opcode, payload, err := readFrame(c.r, true, maxRequestBytes)
+		if err == errFrameTooBig {
+			c.closeWith(wsCloseTooBig)
+			return "", err
+		}
+		if err != nil {
+			c.closeWith(wsCloseProtocolError)
+			return "", err
+		}
+		switch opcode {
+		case wsPing:
+			if err := c.write(wsPong, payload); err != nil {
+				return "", err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			c.closeWith(wsCloseNormal)
+			return "", io.EOF
+		case wsBinary:
+			c.closeWith(wsCloseUnsupported)
+			return "", errors.New("binary messages are not supported")
+		case wsText:
+			if started {
+				c.closeWith(wsCloseProtocolError)
+				return "", errors.New("text frame inside a fragmented message")
+			}
+			started = true
+		case wsContinuation:
+			if !started {
+				c.closeWith(wsCloseProtocolError)
+				return "", errors.New("continuation frame without a message")
+			}
+		default:
+			c.closeWith(wsCloseProtocolError)
+			return "", errors.New("unknown opcode")
+		}
+		if len(message)+len(payload) > maxRequestBytes {
+			c.closeWith(wsCloseTooBig)
+			return "", errFrameTooBig
+		}
+		message = append(message, payload...)
+		if fin {
+			return string(message), nil
+		}
+	}
+}
+
+// WriteMessage sends a text message
+func (c *wsConn) WriteMessage(message string) error {
+	return c.write(wsText, []byte(message))
+}
+
+func (c *wsConn) write(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return errWebSocketClosed
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return writeFrame(c.conn, opcode, payload, false)
+}
+
+// closeWith sends a close frame with the code and closes the connection
+func (c *wsConn) closeWith(code int) {
+	payload := make([]byte, 2)
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	c.write(wsClose, payload)
+	c.Close()
+}
+
+// Close closes the connection without a close frame
+func (c *wsConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.conn.Close()
+}
+
+var errFrameTooBig = errors.New("websocket message too big")
+
+// readFrame reads a frame, client frames must be masked and server frames not
+func readFrame(r io.Reader, masked bool, maxPayload int) (bool, byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin, opcode := header[0]&0x80 != 0, header[0]&0x0f
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, errors.New("reserved bits set")
+	}
+	if (header[1]&0x80 != 0) != masked {
+		return false, 0, nil, errors.New("invalid frame mask")
+	}
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(b[:])
+	}
+	if opcode >= wsClose && (length > 125 || !fin) {
+		return false, 0, nil, errors.New("invalid control frame")
+	}
+	if length > uint64(maxPayload) {
+		return false, 0, nil, errFrameTooBig
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes a final frame, clients mask their frames
+func writeFrame(w io.Writer, opcode byte, payload []byte, masked bool) error {
+	frame := []byte{0x80 | opcode, 0}
+	var maskBit byte
+	if masked {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		frame[1] = maskBit | byte(n)
+	case n <= 0xffff:
+		frame[1] = maskBit | 126
+		frame = append(frame, byte(n>>8), byte(n))
+	default:
+		frame[1] = maskBit | 127
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], uint64(n))
+		frame = append(frame, b[:]...)
+	}
+	if masked {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		for i := range frame[start:] {
+			frame[start+i] ^= mask[i%4]
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+	_, err := w.Write(frame)
+	return err
+}
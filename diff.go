@@ -0,0 +1,298 @@
+package sequencediagram
+
+import "strings"
+
+// DiffOp is the change of a message or participant between two sequence diagrams
+type DiffOp int
+
+const (
+	Same DiffOp = iota
+	Added
+	Removed
+	// Changed messages have the same kind and participants but a different
+	// text or arrow
+	Changed
+)
+
+// MessageDiff is a message of the old and/or the new sequence diagram. Old is
+// nil for added messages and New is nil for removed messages.
+type MessageDiff struct {
+	Op  DiffOp
+	Old Message
+	New Message
+}
+
+// ParticipantDiff is a participant of the old and/or the new sequence diagram
+type ParticipantDiff struct {
+	Op   DiffOp
+	Name string
+}
+
+// DiagramDiff is the difference between two sequence diagrams
+type DiagramDiff struct {
+	Messages     []MessageDiff
+	Participants []ParticipantDiff
+
+	old, new *Diagram
+}
+
+// Diff aligns the messages of a and b on their longest common subsequence.
+// Messages of a that are not in b are removed and messages of b that are not
+// in a are added, unless a removed message has the same kind and participants
+// as an added one next to it, then it is changed. Participants are listed in
+// the order of b, with the removed participants after their predecessor in a.
+func Diff(a, b *Diagram) *DiagramDiff {
+	d := &DiagramDiff{old: a, new: b}
+	am, bm := a.messages, b.messages
+	// lcs[i][j] is the length of the longest common subsequence of am[i:] and bm[j:]
+	lcs := make([][]int, len(am)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bm)+1)
+	}
+	for i := len(am) - 1; i >= 0; i-- {
+		for j := len(bm) - 1; j >= 0; j-- {
+			if am[i].String() == bm[j].String() {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var removed, added []Message
+	for i, j := 0, 0; i < len(am) || j < len(bm); {
+		switch {
+		case i < len(am) && j < len(bm) && am[i].String() == bm[j].String():
+			d.addChanges(removed, added)
+			removed, added = nil, nil
+			d.Messages = append(d.Messages, MessageDiff{Same, am[i], bm[j]})
+			i++
+			j++
+		case j == len(bm) || (i < len(am) && lcs[i+1][j] >= lcs[i][j+1]):
+			removed = append(removed, am[i])
+			i++
+		default:
+			added = append(added, bm[j])
+			j++
+		}
+	}
+	d.addChanges(removed, added)
+
+	for _, node := range mergeNodes(a, b) {
+		_, inA := a.nodes[node]
+		_, inB := b.nodes[node]
+		op := Same
+		if !inA {
+			op = Added
+		} else if !inB {
+			op = Removed
+		}
+		d.Participants = append(d.Participants, ParticipantDiff{op, node})
+	}
+	return d
+}
+
+// addChanges adds the messages that were removed and added between two common
+// messages, pairing each removed message with the next added message of the
+// same kind
+func (d *DiagramDiff) addChanges(removed, added []Message) {
+	var j int
+	for _, r := range removed {
+		k := j
+		for k < len(added) && !sameKind(r, added[k]) {
+			k++
+		}
+		if k == len(added) {
+			d.Messages = append(d.Messages, MessageDiff{Removed, r, nil})
+			continue
+		}
+		for ; j < k; j++ {
+			d.Messages = append(d.Messages, MessageDiff{Added, nil, added[j]})
+		}
+		d.Messages = append(d.Messages, MessageDiff{Changed, r, added[k]})
+		j = k + 1
+	}
+	for ; j < len(added); j++ {
+		d.Messages = append(d.Messages, MessageDiff{Added, nil, added[j]})
+	}
+}
+
+// sameKind reports whether a and b are the same type of message between the
+// same participants
+func sameKind(a, b Message) bool {
+	fromA, toA, okA := endpoints(a)
+	fromB, toB, okB := endpoints(b)
+	if okA || okB {
+		return okA && okB && fromA == fromB && toA == toB
+	}
+	switch a := a.(type) {
+	case Participant:
+		p, ok := b.(Participant)
+		return ok && a.Self.Name == p.Self.Name
+	case Note:
+		n, ok := b.(Note)
+		return ok && a.Node.Name == n.Node.Name && a.Side == n.Side
+	case Title:
+		_, ok := b.(Title)
+		return ok
+	case Divider:
+		_, ok := b.(Divider)
+		return ok
+	case Loop:
+		_, ok := b.(Loop)
+		return ok
+	case Box:
+		_, ok := b.(Box)
+		return ok
+	}
+	return false
+}
+
+// endpoints returns the names of the nodes of a message with an arrow
+func endpoints(message Message) (string, string, bool) {
+	switch m := message.(type) {
+	case SelfMessage:
+		return m.Self.Name, m.Self.Name, true
+	case ForwardMessage:
+		return m.From.Name, m.To.Name, true
+	case BackwardMessage:
+		return m.From.Name, m.To.Name, true
+	}
+	return "", "", false
+}
+
+// mergeNodes returns the node names of b in order, with the nodes that are only
+// in a after their predecessor in a
+func mergeNodes(a, b *Diagram) []string {
+	var names []string
+	for _, node := range b.GetOrderedNodes() {
+		names = append(names, node.Name)
+	}
+	for i, node := range a.GetOrderedNodes() {
+		if _, ok := b.nodes[node.Name]; ok {
+			continue
+		}
+		at := 0
+		if i > 0 {
+			prev := a.GetOrderedNodes()[i-1].Name
+			for k, name := range names {
+				if name == prev {
+					at = k + 1
+				}
+			}
+		}
+		names = append(names[:at], append([]string{node.Name}, names[at:]...)...)
+	}
+	return names
+}
+
+// Equal reports whether the sequence diagrams have the same messages
+func (d *DiagramDiff) Equal() bool {
+	for _, m := range d.Messages {
+		if m.Op != Same {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the messages of the diff, prefixed with "+ " if added, "- "
+// if removed and "  " otherwise. A changed message is removed and added.
+func (d *DiagramDiff) String() string {
+	var lines []string
+	for _, m := range d.Messages {
+		switch m.Op {
+		case Same:
+			lines = append(lines, "  "+m.New.String())
+		case Added:
+			lines = append(lines, "+ "+m.New.String())
+		case Removed:
+			lines = append(lines, "- "+m.Old.String())
+		case Changed:
+			lines = append(lines, "- "+m.Old.String(), "+ "+m.New.String())
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Merged returns a sequence diagram with the messages of both sequence
+// diagrams and the op of each of its messages. Changed messages are a removed
+// message followed by an added one. The names of added and removed
+// participants start with "+" and "-".
+func (d *DiagramDiff) Merged() (*Diagram, []DiffOp) {
+	sd := &Diagram{}
+	names := make(map[string]string)
+	for _, p := range d.Participants {
+		name := p.Name
+		switch p.Op {
+		case Added:
+			name = "+" + name
+		case Removed:
+			name = "-" + name
+		}
+		names[p.Name] = name
+		sd.getOrCreateNode(name)
+	}
+	node := func(n *Node) *Node {
+		return sd.nodes[names[n.Name]]
+	}
+
+	// groups of the new diagram come first, a node is only in the first
+	// group that has it
+	groups := make(map[string]*Group)
+	grouped := make(map[*Node]bool)
+	for _, diagram := range []*Diagram{d.new, d.old} {
+		for _, group := range diagram.groups {
+			g, ok := groups[group.Name]
+			if !ok {
+				g = &Group{Name: group.Name}
+				groups[group.Name] = g
+				sd.groups = append(sd.groups, g)
+			}
+			for _, n := range group.Nodes {
+				if n := node(n); !grouped[n] {
+					grouped[n] = true
+					g.Nodes = append(g.Nodes, n)
+				}
+			}
+		}
+	}
+
+	var ops []DiffOp
+	add := func(message Message, op DiffOp) {
+		switch m := message.(type) {
+		case Participant:
+			m.Self = node(m.Self)
+			message = m
+		case SelfMessage:
+			message = classifyMessage(node(m.Self), node(m.Self), m.simpleMessage, m.uniDirectionalMessage)
+		case ForwardMessage:
+			message = classifyMessage(node(m.From), node(m.To), m.simpleMessage, m.uniDirectionalMessage)
+		case BackwardMessage:
+			message = classifyMessage(node(m.From), node(m.To), m.simpleMessage, m.uniDirectionalMessage)
+		case Note:
+			m.Node = node(m.Node)
+			message = m
+		case Box:
+			m.Group = groups[m.Group.Name]
+			message = m
+		}
+		sd.messages = append(sd.messages, message)
+		ops = append(ops, op)
+	}
+	for _, m := range d.Messages {
+		switch m.Op {
+		case Same, Added:
+			add(m.New, m.Op)
+		case Removed:
+			add(m.Old, Removed)
+		case Changed:
+			add(m.Old, Removed)
+			add(m.New, Added)
+		}
+	}
+	// keep the groups together and set the direction of the messages
+	sd.setOrder(sd.GetOrderedNodes())
+	return sd, ops
+}
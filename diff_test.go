@@ -0,0 +1,73 @@
+package sequencediagram
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		a, b         string
+		want         string
+		participants []ParticipantDiff
+	}{
+		{"a->b:x\nb-->a:y", "a->b:x\nb-->a:y", "  a->b:x\n  b-->a:y",
+			[]ParticipantDiff{{Same, "a"}, {Same, "b"}}},
+		{"a->b:x\nb-->a:y", "a->b:x\nb->c:z\nc-->b:w\nb-->a:y", "  a->b:x\n+ b->c:z\n+ c-->b:w\n  b-->a:y",
+			[]ParticipantDiff{{Same, "a"}, {Same, "b"}, {Added, "c"}}},
+		{"a->b:x\nb->c:z\nb-->a:y", "a->b:x\nb-->a:y", "  a->b:x\n- b->c:z\n  b-->a:y",
+			[]ParticipantDiff{{Same, "a"}, {Same, "b"}, {Removed, "c"}}},
+		{"a->b:x\nb-->a:200 OK", "a->b:x\nb-->a:500 Error", "  a->b:x\n- b-->a:200 OK\n+ b-->a:500 Error",
+			[]ParticipantDiff{{Same, "a"}, {Same, "b"}}},
+		{"a->b:x\nnote left of a:n", "a->c:x\nnote right of a:n", "- a->b:x\n- note left of a:n\n+ a->c:x\n+ note right of a:n",
+			[]ParticipantDiff{{Same, "a"}, {Removed, "b"}, {Added, "c"}}},
+	}
+	for _, test := range tests {
+		a, err := ParseFromText(test.a)
+		if err != nil {
+			t.Fatalf("error parsing sequence diagram: %v", err)
+		}
+		b, err := ParseFromText(test.b)
+		if err != nil {
+			t.Fatalf("error parsing sequence diagram: %v", err)
+		}
+		d := Diff(a, b)
+		if got := d.String(); got != test.want {
+			t.Errorf("TestDiff => input: %q %q, got:\n%s\nwant:\n%s", test.a, test.b, got, test.want)
+		}
+		if !reflect.DeepEqual(d.Participants, test.participants) {
+			t.Errorf("TestDiff => input: %q %q, got participants %v, want %v", test.a, test.b, d.Participants, test.participants)
+		}
+		if d.Equal() != (test.a == test.b) {
+			t.Errorf("TestDiff => input: %q %q, got Equal() %v", test.a, test.b, d.Equal())
+		}
+	}
+}
+
+func TestDiffChanged(t *testing.T) {
+	a, _ := ParseFromText("a->b:x\nb-->a:200 OK")
+	b, _ := ParseFromText("a->b:x\nb->a:200 OK")
+	var ops []DiffOp
+	for _, m := range Diff(a, b).Messages {
+		ops = append(ops, m.Op)
+	}
+	if want := []DiffOp{Same, Changed}; !reflect.DeepEqual(ops, want) {
+		t.Errorf("TestDiffChanged => got ops %v, want %v", ops, want)
+	}
+}
+
+func TestDiffMerged(t *testing.T) {
+	a, _ := ParseFromText("box \"Backend\"\nparticipant b\nparticipant c\nend box\na->b:x\nb->c:z\nb-->a:y")
+	b, _ := ParseFromText("box \"Backend\"\nparticipant b\nend box\na->b:x\nb-->a:w")
+	sd, ops := Diff(a, b).Merged()
+	want := "box \"Backend\"\nparticipant b\nparticipant -c\nend box\na->b:x\nb->-c:z\nb-->a:y\nb-->a:w"
+	if got := sd.String(); got != want {
+		t.Errorf("TestDiffMerged => got:\n%s\nwant:\n%s", got, want)
+	}
+	if want := []DiffOp{Same, Same, Removed, Same, Same, Removed, Removed, Added}; !reflect.DeepEqual(ops, want) {
+		t.Errorf("TestDiffMerged => got ops %v, want %v", ops, want)
+	}
+	if got, want := nodeNames(sd.GetOrderedNodes()), []string{"b", "-c", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TestDiffMerged => got nodes %v, want %v", got, want)
+	}
+}
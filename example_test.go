@@ -25,3 +25,19 @@ Sister->Dad:tattle`
 	// note left of Sister:*eavesdrop*
 	// Sister->Dad:tattle
 }
+
+func ExampleRecorder() {
+	var r sequencediagram.Recorder
+	r.Call("Client", "Server", "GET /users")
+	r.Note("Server", sequencediagram.Right, "cache miss")
+	r.Call("Server", "Database", "SELECT users")
+	r.Return("Database", "Server", "3 rows")
+	r.Return("Server", "Client", "200 OK")
+	fmt.Println(r.Diagram())
+	// Output:
+	// Client->Server:GET /users
+	// note right of Server:cache miss
+	// Server->Database:SELECT users
+	// Database-->Server:3 rows
+	// Server-->Client:200 OK
+}
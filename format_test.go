@@ -0,0 +1,248 @@
+package sequencediagram
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestFormats(t *testing.T) {
+	tests := []struct {
+		name   string
+		format func(*Diagram) string
+		ext    string
+	}{
+		{"tattler", (*Diagram).Mermaid, ".mmd"},
+		{"tattler", (*Diagram).PlantUML, ".puml"},
+		{"features", (*Diagram).Mermaid, ".mmd"},
+		{"features", (*Diagram).PlantUML, ".puml"},
+		{"sections", (*Diagram).Mermaid, ".mmd"},
+		{"sections", (*Diagram).PlantUML, ".puml"},
+		{"loops", (*Diagram).Mermaid, ".mmd"},
+		{"loops", (*Diagram).PlantUML, ".puml"},
+	}
+	for _, test := range tests {
+		sd, err := ParseFromText(readFile(t, "testdata/"+test.name+".sd"))
+		if err != nil {
+			t.Fatalf("error parsing sequence diagram: %v", err)
+		}
+		want := readFile(t, "testdata/"+test.name+test.ext)
+		if got := test.format(sd); got != want {
+			t.Errorf("TestFormats => input: %s%s, got:\n%s\nwant:\n%s", test.name, test.ext, got, want)
+		}
+	}
+}
+
+func TestParseFromMermaid(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"sequenceDiagram", ""},
+		{"%% comment\n\nsequenceDiagram\n  title: Title\n  Alice->>John: Hello<br>John", "title Title\nAlice->John:Hello\\nJohn"},
+		{"sequenceDiagram\nA->B: solid\nA-->B: dashed\nA->>B: arrow\nA-->>B: dashed arrow\nA-)B: open\nA--)B: dashed open",
+			"A->B:solid\nA-->B:dashed\nA->B:arrow\nA-->B:dashed arrow\nA->>B:open\nA-->>B:dashed open"},
+		{"sequenceDiagram\nparticipant A as Alice\nactor B\nA->>B: hi", "participant Alice\nparticipant B\nAlice->B:hi"},
+		{"sequenceDiagram\nNote right of A: r\nNote left of A: l\nNote over A: o", "note right of A:r\nnote left of A:l\nnote right of A:o"},
+		{"sequenceDiagram\nparticipant A\nparticipant B\nNote over B,A: o", "participant A\nparticipant B\nnote right of A:o"},
+		{"sequenceDiagram\nbox rgb(1,2,3) Backend\nparticipant S\nend\nS->>S: x#59;y", "box \"Backend\"\nparticipant S\nend box\nS->S:x;y"},
+		{"sequenceDiagram\n%% == Start ==\nA->>B: x\n%% == a <br/> b ==\nNote over A,B: a <br/> b\nNote over A,B: kept", "== Start ==\nA->B:x\n== a \\n b ==\nnote right of A:kept"},
+		{"sequenceDiagram\nloop Every#59; minute\nloop Retry\nA->>B: hi\nend\nend", "loop Every; minute\nloop Retry\nA->B:hi\nend\nend"},
+	}
+	for _, test := range tests {
+		sd, err := ParseFromMermaid(test.text)
+		if err != nil {
+			t.Errorf("TestParseFromMermaid => input: %q, got error: %v", test.text, err)
+			continue
+		}
+		if sd.String() != test.want {
+			t.Errorf("TestParseFromMermaid => input: %q, got %q, want %q", test.text, sd, test.want)
+		}
+	}
+}
+
+func TestParseFromMermaidErrors(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"", "Line 1: Expected sequenceDiagram."},
+		{"graph TD", "Line 1: Expected sequenceDiagram."},
+		{"sequenceDiagram\nA->>B: hi\nloop Every minute\nA->>B: hi", "Line 3: Loop is never ended."},
+		{"sequenceDiagram\nA->>B: hi\nend", "Line 3: Unsupported statement end."},
+		{"sequenceDiagram\n\nactivate A", "Line 3: Unsupported statement activate."},
+		{"sequenceDiagram\nA-xB: lost", "Line 2: Syntax error."},
+		{"sequenceDiagram\nNote over A,B,C: x", "Line 2: Note must be placed next to one participant."},
+		{"sequenceDiagram\nbox Backend\nparticipant S", "Line 2: Box is never ended."},
+		{"sequenceDiagram\nbox Backend\nA->>B: hi\nend", "Line 3: Only participants can be declared in a box."},
+	}
+	for _, test := range tests {
+		_, err := ParseFromMermaid(test.text)
+		if err == nil || err.Error() != test.want {
+			t.Errorf("TestParseFromMermaidErrors => input: %q, got error %v, want %q", test.text, err, test.want)
+		}
+	}
+}
+
+// exported diagrams must import to the same nodes and messages
+func TestMermaidRoundTrip(t *testing.T) {
+	for _, name := range []string{"tattler", "features", "sections", "loops"} {
+		sd, err := ParseFromText(readFile(t, "testdata/"+name+".sd"))
+		if err != nil {
+			t.Fatalf("error parsing sequence diagram: %v", err)
+		}
+		golden := readFile(t, "testdata/"+name+".mmd")
+		imported, err := ParseFromMermaid(golden)
+		if err != nil {
+			t.Errorf("TestMermaidRoundTrip => %s: got error: %v", name, err)
+			continue
+		}
+		assertSameDiagram(t, name, imported, sd)
+		if got := imported.Mermaid(); got != golden {
+			t.Errorf("TestMermaidRoundTrip => %s: got:\n%s\nwant:\n%s", name, got, golden)
+		}
+	}
+}
+
+func TestParseFromPlantUML(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"@startuml\n@enduml", ""},
+		{"' comment\ntitle Title\nAlice -> Bob : hello", "title Title\nAlice->Bob:hello"},
+		{"A -> B : solid\nA --> B : dashed\nA ->> B : open\nA -->> B : dashed open\nA -> B",
+			"A->B:solid\nA-->B:dashed\nA->>B:open\nA-->>B:dashed open\nA->B:"},
+		{"A <- B : back\nA <<-- B : dashed open back", "B->A:back\nB-->>A:dashed open back"},
+		{"actor A\ndatabase \"Data Base\" as D\nparticipant E as \"Entity\" #red\nA -> D : q\nD -> \"Entity\" : e",
+			"participant A\nparticipant Data Base\nparticipant Entity\nA->Data Base:q\nData Base->Entity:e"},
+		{"participant B order 2\nparticipant A order 1\nA -> B : hi", "participant B order 2\nparticipant A order 1\nA->B:hi"},
+		{"note left of A : l\nnote right of A : r\nnote over A : o", "note left of A:l\nnote right of A:r\nnote right of A:o"},
+		{"box \"Backend\" #LightBlue\nparticipant S\nend box\n== Start ==", "box \"Backend\"\nparticipant S\nend box\n== Start =="},
+		{"loop 10 times\n  A -> B : hi\nend", "loop 10 times\nA->B:hi\nend"},
+		{"A -> B : before\n@enduml\nignored", "A->B:before"},
+	}
+	for _, test := range tests {
+		sd, err := ParseFromPlantUML(test.text)
+		if err != nil {
+			t.Errorf("TestParseFromPlantUML => input: %q, got error: %v", test.text, err)
+			continue
+		}
+		if sd.String() != test.want {
+			t.Errorf("TestParseFromPlantUML => input: %q, got %q, want %q", test.text, sd, test.want)
+		}
+	}
+}
+
+func TestParseFromPlantUMLErrors(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"@startuml\nA -> B : hi\nactivate B\nloop 10 times\nA -> B : hi\n@enduml",
+			"Line 3: Unsupported statement activate.\nLine 4: Loop is never ended."},
+		{"note left of A\nmulti-line\nend note", "Line 1: Unsupported statement note.\nLine 2: Syntax error.\nLine 3: Unsupported statement end."},
+		{"box \"B\"\nA -> B : hi", "Line 1: Box is never ended.\nLine 2: Only participants can be declared in a box."},
+		{"A -x B : lost", "Line 1: Syntax error."},
+	}
+	for _, test := range tests {
+		_, err := ParseFromPlantUML(test.text)
+		if err == nil || err.Error() != test.want {
+			t.Errorf("TestParseFromPlantUMLErrors => input: %q, got error %v, want %q", test.text, err, test.want)
+		}
+	}
+}
+
+// exported diagrams must import to the same nodes and messages
+func TestPlantUMLRoundTrip(t *testing.T) {
+	for _, name := range []string{"tattler", "features", "sections"} {
+		sd, err := ParseFromText(readFile(t, "testdata/"+name+".sd"))
+		if err != nil {
+			t.Fatalf("error parsing sequence diagram: %v", err)
+		}
+		golden := readFile(t, "testdata/"+name+".puml")
+		imported, err := ParseFromPlantUML(golden)
+		if err != nil {
+			t.Errorf("TestPlantUMLRoundTrip => %s: got error: %v", name, err)
+			continue
+		}
+		assertSameDiagram(t, name, imported, sd)
+		if got := imported.PlantUML(); got != golden {
+			t.Errorf("TestPlantUMLRoundTrip => %s: got:\n%s\nwant:\n%s", name, got, golden)
+		}
+	}
+}
+
+// assertSameDiagram checks got has the same nodes, groups, title and
+// messages as want, ignoring participant declarations
+func assertSameDiagram(t *testing.T, name string, got, want *Diagram) {
+	if g, w := nodeNames(got.GetOrderedNodes()), nodeNames(want.GetOrderedNodes()); !reflect.DeepEqual(g, w) {
+		t.Errorf("%s: got nodes %v, want %v", name, g, w)
+	}
+	if g, w := got.groupedNodes(), want.groupedNodes(); len(g) != len(w) {
+		t.Errorf("%s: got %d node groups, want %d", name, len(g), len(w))
+	}
+	gotTitle, _ := got.lastTitle()
+	wantTitle, _ := want.lastTitle()
+	if gotTitle != wantTitle {
+		t.Errorf("%s: got title %q, want %q", name, gotTitle, wantTitle)
+	}
+	if g, w := messageStrings(got), messageStrings(want); !reflect.DeepEqual(g, w) {
+		t.Errorf("%s: got messages %q, want %q", name, g, w)
+	}
+}
+
+// messageStrings returns the messages and notes of the sequence diagram
+func messageStrings(sd *Diagram) []string {
+	var messages []string
+	for _, message := range sd.Messages() {
+		switch message.(type) {
+		case SelfMessage, ForwardMessage, BackwardMessage, Note, Divider:
+			messages = append(messages, message.String())
+		}
+	}
+	return messages
+}
+
+func readFile(t *testing.T, filename string) string {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("error reading file: %v", err)
+	}
+	return string(bytes.TrimSpace(b))
+}
+
+func TestMermaidBoxes(t *testing.T) {
+	sd, err := ParseFromText("box \"Empty\"\nend box\nbox \"Backend\"\nparticipant S\nend box\nC->S:x")
+	if err != nil {
+		t.Fatalf("error parsing sequence diagram: %v", err)
+	}
+	want := "sequenceDiagram\nbox Backend\nparticipant S\nend\nparticipant C\nbox Empty\nend\nC->>S: x"
+	if got := sd.Mermaid(); got != want {
+		t.Errorf("TestMermaidBoxes => got:\n%s\nwant:\n%s", got, want)
+	}
+	imported, err := ParseFromMermaid(want)
+	if err != nil {
+		t.Fatalf("TestMermaidBoxes => got error: %v", err)
+	}
+	if got := len(imported.Groups()); got != 2 {
+		t.Errorf("TestMermaidBoxes => imported %d boxes, want 2", got)
+	}
+}
+
+func TestPlantUMLEmptyLabel(t *testing.T) {
+	sd, err := ParseFromPlantUML("A -> B\nB --> A : ok\nA -> A")
+	if err != nil {
+		t.Fatalf("error parsing PlantUML: %v", err)
+	}
+	want := "@startuml\nparticipant A\nparticipant B\nA -> B\nB --> A : ok\nA -> A\n@enduml"
+	if got := sd.PlantUML(); got != want {
+		t.Errorf("TestPlantUMLEmptyLabel => got:\n%s\nwant:\n%s", got, want)
+	}
+	imported, err := ParseFromPlantUML(want)
+	if err != nil {
+		t.Fatalf("TestPlantUMLEmptyLabel => got error: %v", err)
+	}
+	assertSameDiagram(t, "TestPlantUMLEmptyLabel", imported, sd)
+}
@@ -0,0 +1,117 @@
+package sequencediagram
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// HARBrowser is the name of the node that sends the requests of a HAR file
+const HARBrowser = "Browser"
+
+// HARFilter selects the entries of a HAR file that are added to the sequence
+// diagram. Patterns use the syntax of path.Match, an empty list keeps all entries.
+type HARFilter struct {
+	// Hosts are patterns for the request host, e.g. "*.example.com"
+	Hosts []string
+	// ContentTypes are patterns for the response media type, e.g. "image/*"
+	ContentTypes []string
+}
+
+// ReadHARFile parses the HTTP Archive file into a sequence diagram, see ParseFromHAR
+func ReadHARFile(filename string, filter HARFilter) (*Diagram, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseFromHAR(f, filter)
+}
+
+// ParseFromHAR parses an HTTP Archive (HAR) into a sequence diagram. Each host
+// is a node, each request is a message from the browser labelled with the
+// method and path, and each response is a dashed message back labelled with
+// the status and duration. Messages are ordered by time.
+func ParseFromHAR(r io.Reader, filter HARFilter) (*Diagram, error) {
+	var har struct {
+		Log struct {
+			Entries []struct {
+				StartedDateTime time.Time `json:"startedDateTime"`
+				Time            float64   `json:"time"`
+				Request         struct {
+					Method string `json:"method"`
+					URL    string `json:"url"`
+				} `json:"request"`
+				Response struct {
+					Status     int    `json:"status"`
+					StatusText string `json:"statusText"`
+					Content    struct {
+						MimeType string `json:"mimeType"`
+					} `json:"content"`
+				} `json:"response"`
+			} `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.NewDecoder(r).Decode(&har); err != nil {
+		return nil, fmt.Errorf("error decoding HAR: %v", err)
+	}
+
+	type timedMessage struct {
+		time          time.Time
+		from, to, msg string
+		altArrowBody  bool
+	}
+	var messages []timedMessage
+	for i, entry := range har.Log.Entries {
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %v", i+1, err)
+		}
+		mediaType, _, _ := mime.ParseMediaType(entry.Response.Content.MimeType)
+		if !matchAny(filter.Hosts, u.Host) || !matchAny(filter.ContentTypes, mediaType) {
+			continue
+		}
+		duration := time.Duration(entry.Time * float64(time.Millisecond))
+		response := strconv.Itoa(entry.Response.Status)
+		if entry.Response.StatusText != "" {
+			response += " " + entry.Response.StatusText
+		}
+		response += " (" + duration.Round(time.Millisecond).String() + ")"
+		requestPath := u.EscapedPath()
+		if requestPath == "" {
+			requestPath = "/"
+		}
+		messages = append(messages,
+			timedMessage{entry.StartedDateTime, HARBrowser, u.Host, entry.Request.Method + " " + requestPath, false},
+			timedMessage{entry.StartedDateTime.Add(duration), u.Host, HARBrowser, response, true})
+	}
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i].time.Before(messages[j].time) })
+
+	sd := &Diagram{}
+	sd.messages = append(sd.messages, Participant{Self: sd.getOrCreateNode(HARBrowser)})
+	for _, m := range messages {
+		from, to := sd.getOrCreateNode(m.from), sd.getOrCreateNode(m.to)
+		sd.messages = append(sd.messages, classifyMessage(from, to, simpleMessage{m.msg}, uniDirectionalMessage{AltArrowBody: m.altArrowBody}))
+	}
+	return sd, nil
+}
+
+// matchAny reports whether s matches one of the patterns, or there are no patterns
+func matchAny(patterns []string, s string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, s); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package sequencediagram
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadHARFile(t *testing.T) {
+	tests := []struct {
+		filter HARFilter
+		want   string
+	}{
+		{HARFilter{}, `participant Browser
+Browser->www.example.com:GET /
+www.example.com-->Browser:200 OK (120ms)
+Browser->cdn.example.net:GET /app.js
+Browser->api.example.com:POST /v1/login
+api.example.com-->Browser:401 Unauthorized (45ms)
+cdn.example.net-->Browser:200 OK (80ms)
+Browser->cdn.example.net:GET /logo.png
+cdn.example.net-->Browser:304 (10ms)`},
+		{HARFilter{Hosts: []string{"*.example.com"}}, `participant Browser
+Browser->www.example.com:GET /
+www.example.com-->Browser:200 OK (120ms)
+Browser->api.example.com:POST /v1/login
+api.example.com-->Browser:401 Unauthorized (45ms)`},
+		{HARFilter{ContentTypes: []string{"image/*", "text/html"}}, `participant Browser
+Browser->www.example.com:GET /
+www.example.com-->Browser:200 OK (120ms)
+Browser->cdn.example.net:GET /logo.png
+cdn.example.net-->Browser:304 (10ms)`},
+		{HARFilter{Hosts: []string{"none"}}, `participant Browser`},
+	}
+	for _, test := range tests {
+		sd, err := ReadHARFile("testdata/session.har", test.filter)
+		if err != nil {
+			t.Errorf("TestReadHARFile => filter %v: got error: %v", test.filter, err)
+			continue
+		}
+		if sd.String() != test.want {
+			t.Errorf("TestReadHARFile => filter %v: got:\n%s\nwant:\n%s", test.filter, sd, test.want)
+		}
+	}
+}
+
+func TestParseFromHARErrors(t *testing.T) {
+	tests := []struct {
+		text string
+	}{
+		{"not json"},
+		{`{"log": {"entries": [{"startedDateTime": "yesterday"}]}}`},
+		{`{"log": {"entries": [{"request": {"url": "http://a b/%zz"}}]}}`},
+	}
+	for _, test := range tests {
+		if _, err := ParseFromHAR(strings.NewReader(test.text), HARFilter{}); err == nil {
+			t.Errorf("TestParseFromHARErrors => input: %q, expected error", test.text)
+		}
+	}
+}
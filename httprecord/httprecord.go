@@ -0,0 +1,146 @@
+// package httprecord records HTTP exchanges between services as a sequence diagram
+package httprecord
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Laugusti/sequencediagram"
+)
+
+const (
+	// CallerHeader is set on outbound requests to the name of the calling participant
+	CallerHeader = "X-Sequence-Caller"
+	// RecorderHeader is set on responses by a handler that recorded the exchange,
+	// so the transport of the caller does not record it again
+	RecorderHeader = "X-Sequence-Recorder"
+	// DefaultCaller is the participant name used for requests without a CallerHeader
+	DefaultCaller = "Client"
+)
+
+type contextKey struct{}
+
+// ParticipantFromContext returns the name of the participant handling the
+// request the context belongs to
+func ParticipantFromContext(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(contextKey{}).(string)
+	return name, ok
+}
+
+// Handler returns middleware that records each request handled by next as a
+// call from the participant named in the CallerHeader to name, followed by a
+// return with the response status. The request context carries name, so a
+// Transport used with that context makes its calls as name.
+func Handler(rec *sequencediagram.Recorder, name string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller := r.Header.Get(CallerHeader)
+		if caller == "" {
+			caller = DefaultCaller
+		} else {
+			// only a recording Transport sends the CallerHeader and reads the id
+			w.Header().Set(RecorderHeader, rec.ID())
+		}
+		rec.Call(caller, name, requestLabel(r))
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() { rec.Return(name, caller, statusLabel(sw.status())) }()
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), contextKey{}, name)))
+	})
+}
+
+// Transport is an http.RoundTripper that records each request it sends as a
+// call from Name to the host of the request, followed by a return with the
+// response status. Exchanges with a server that recorded them with the same
+// Recorder (see Handler) are not recorded twice.
+type Transport struct {
+	Recorder *sequencediagram.Recorder
+	// Name is the calling participant. If empty, the participant from the request
+	// context is used, or DefaultCaller if there is none.
+	Name string
+	// Base sends the requests. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	caller := t.Name
+	if caller == "" {
+		caller, _ = ParticipantFromContext(req.Context())
+	}
+	if caller == "" {
+		caller = DefaultCaller
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	out := req.Clone(req.Context())
+	out.Header.Set(CallerHeader, caller)
+	resp, err := base.RoundTrip(out)
+	if err != nil {
+		t.Recorder.Call(caller, req.URL.Host, requestLabel(req))
+		t.Recorder.Return(req.URL.Host, caller, "error: "+err.Error())
+		return nil, err
+	}
+	if resp.Header.Get(RecorderHeader) != t.Recorder.ID() {
+		t.Recorder.Call(caller, req.URL.Host, requestLabel(req))
+		t.Recorder.Return(req.URL.Host, caller, statusLabel(resp.StatusCode))
+	}
+	return resp, nil
+}
+
+func requestLabel(r *http.Request) string {
+	requestPath := r.URL.EscapedPath()
+	if requestPath == "" {
+		requestPath = "/"
+	}
+	return r.Method + " " + requestPath
+}
+
+func statusLabel(status int) string {
+	return strings.TrimSpace(strconv.Itoa(status) + " " + http.StatusText(status))
+}
+
+// statusWriter remembers the status code written to the response
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	if sw.code == 0 {
+		sw.code = code
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.code == 0 {
+		sw.code = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher if the underlying response writer does
+func (sw *statusWriter) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		if sw.code == 0 {
+			sw.code = http.StatusOK
+		}
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying response writer for http.ResponseController
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+func (sw *statusWriter) status() int {
+	if sw.code == 0 {
+		return http.StatusOK
+	}
+	return sw.code
+}
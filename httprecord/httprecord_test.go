@@ -0,0 +1,93 @@
+package httprecord
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Laugusti/sequencediagram"
+)
+
+func TestRecordExchanges(t *testing.T) {
+	var rec sequencediagram.Recorder
+
+	// an external service that does not record its requests
+	external := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer external.Close()
+
+	backend := httptest.NewServer(Handler(&rec, "Backend", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "missing", http.StatusNotFound)
+	})))
+	defer backend.Close()
+
+	client := &http.Client{Transport: &Transport{Recorder: &rec}}
+	frontend := httptest.NewServer(Handler(&rec, "Frontend", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, url := range []string{backend.URL + "/users/1", external.URL} {
+			req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, url, nil)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Errorf("TestRecordExchanges => got error calling %s: %v", url, err)
+				continue
+			}
+			resp.Body.Close()
+		}
+		io.WriteString(w, "done")
+	})))
+	defer frontend.Close()
+
+	test := &http.Client{Transport: &Transport{Recorder: &rec, Name: "Test"}}
+	resp, err := test.Post(frontend.URL+"/checkout?id=1", "text/plain", nil)
+	if err != nil {
+		t.Fatalf("TestRecordExchanges => got error: %v", err)
+	}
+	resp.Body.Close()
+	// requests without a caller header come from the default caller
+	resp, err = http.Get(backend.URL)
+	if err != nil {
+		t.Fatalf("TestRecordExchanges => got error: %v", err)
+	}
+	resp.Body.Close()
+	// only recording transports get the id of the recorder
+	if id := resp.Header.Get(RecorderHeader); id != "" {
+		t.Errorf("TestRecordExchanges => got %s %q for a request without a caller", RecorderHeader, id)
+	}
+
+	want := `Test->Frontend:POST /checkout
+Frontend->Backend:GET /users/1
+Backend-->Frontend:404 Not Found
+Frontend->External:GET /
+External-->Frontend:418 I'm a teapot
+Frontend-->Test:200 OK
+Client->Backend:GET /
+Backend-->Client:404 Not Found`
+	got := strings.ReplaceAll(rec.Diagram().String(), strings.TrimPrefix(external.URL, "http://"), "External")
+	if got != want {
+		t.Errorf("TestRecordExchanges => got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTransportError(t *testing.T) {
+	var rec sequencediagram.Recorder
+	server := httptest.NewServer(http.NotFoundHandler())
+	host := strings.TrimPrefix(server.URL, "http://")
+	server.Close()
+
+	client := &http.Client{Transport: &Transport{Recorder: &rec, Name: "Test"}}
+	if _, err := client.Get(server.URL); err == nil {
+		t.Fatal("TestTransportError => expected error")
+	}
+	messages := rec.Diagram().Messages()
+	if len(messages) != 2 {
+		t.Fatalf("TestTransportError => expected 2 messages, got %d", len(messages))
+	}
+	if got, want := messages[0].String(), "Test->"+host+":GET /"; got != want {
+		t.Errorf("TestTransportError => expected %q, got %q", want, got)
+	}
+	if got := messages[1].MessageText(); !strings.HasPrefix(got, "error: ") {
+		t.Errorf("TestTransportError => expected an error return, got %q", got)
+	}
+}
@@ -0,0 +1,138 @@
+// package cells splits a text diagram into cells, one per rune, and describes
+// the lines drawn in the cells of box drawing runes and arrows. The svg and png
+// encoders draw the same cells.
+package cells
+
+import "strings"
+
+// Point is a point of a cell that lines start or end at
+type Point int
+
+const (
+	Center Point = iota
+	Left
+	Right
+	Top
+	Bottom
+)
+
+// Style is the stroke of a line
+type Style int
+
+const (
+	Solid Style = iota
+	Double
+	Dashed
+)
+
+// Head is the arrow head drawn in a cell, with the tip at the edge of the cell
+type Head int
+
+const (
+	NoHead Head = iota
+	FilledRight
+	FilledLeft
+	OpenRight
+	OpenLeft
+)
+
+// Segment is a straight line between two points of a cell
+type Segment struct {
+	From, To Point
+	Style    Style
+}
+
+// Shape is what is drawn in a cell instead of its rune. Fold is the folded
+// corner of a note.
+type Shape struct {
+	Segments []Segment
+	Head     Head
+	Fold     bool
+}
+
+// shapes are the shapes of the box drawing runes of the text diagram
+var shapes = map[rune]Shape{
+	'─': {Segments: []Segment{{Left, Right, Solid}}},
+	'═': {Segments: []Segment{{Left, Right, Double}}},
+	'│': {Segments: []Segment{{Top, Bottom, Solid}}},
+	'‖': {Segments: []Segment{{Top, Bottom, Double}}},
+	'¦': {Segments: []Segment{{Top, Bottom, Dashed}}},
+	'┌': {Segments: []Segment{{Right, Center, Solid}, {Center, Bottom, Solid}}},
+	'┐': {Segments: []Segment{{Left, Center, Solid}, {Center, Bottom, Solid}}},
+	'╗': {Segments: []Segment{{Left, Center, Solid}, {Center, Bottom, Solid}}, Fold: true},
+	'└': {Segments: []Segment{{Right, Center, Solid}, {Center, Top, Solid}}},
+	'┘': {Segments: []Segment{{Left, Center, Solid}, {Center, Top, Solid}}},
+	'┤': {Segments: []Segment{{Top, Bottom, Solid}, {Left, Center, Solid}}},
+	'├': {Segments: []Segment{{Top, Bottom, Solid}, {Center, Right, Solid}}},
+	'▶': {Head: FilledRight},
+	'◀': {Head: FilledLeft},
+}
+
+var (
+	dashedBody = &Shape{Segments: []Segment{{Left, Right, Dashed}}}
+	openRight  = &Shape{Head: OpenRight}
+	openLeft   = &Shape{Head: OpenLeft}
+)
+
+// Grid returns the runes of each line of the text diagram and the number of
+// runes of the longest line
+func Grid(text string) (rows [][]rune, width int) {
+	for _, line := range strings.Split(text, "\n") {
+		row := []rune(line)
+		if len(row) > width {
+			width = len(row)
+		}
+		rows = append(rows, row)
+	}
+	return rows, width
+}
+
+// Row returns the shape of each cell of the row, nil for the cells drawn as
+// text. A run of "-" is the body of a dashed arrow when it touches the box of
+// the message or an arrow end, labels are padded and never do, and "<" and
+// ">" at the end of an arrow body are open arrow heads.
+func Row(row []rune) []*Shape {
+	result := make([]*Shape, len(row))
+	for i, r := range row {
+		if shape, ok := shapes[r]; ok {
+			result[i] = &shape
+		}
+	}
+	for start := 0; start < len(row); start++ {
+		if row[start] != '-' {
+			continue
+		}
+		end := start
+		for end < len(row) && row[end] == '-' {
+			end++
+		}
+		if arrowEnd(row, start-1) || arrowEnd(row, end) {
+			for i := start; i < end; i++ {
+				result[i] = dashedBody
+			}
+		}
+		start = end
+	}
+	for i, r := range row {
+		switch {
+		case r == '>' && i > 0 && body(row, result, i-1):
+			result[i] = openRight
+		case r == '<' && i+1 < len(row) && body(row, result, i+1):
+			result[i] = openLeft
+		}
+	}
+	return result
+}
+
+// arrowEnd reports whether the rune at i ends the body of an arrow
+func arrowEnd(row []rune, i int) bool {
+	if i < 0 || i >= len(row) {
+		return false
+	}
+	return strings.ContainsRune("◀▶┤├┐┘", row[i])
+}
+
+// body reports whether the cell at i is drawn as the body of an arrow
+func body(row []rune, shapes []*Shape, i int) bool {
+	return shapes[i] == dashedBody || row[i] == '─'
+}
@@ -0,0 +1,45 @@
+package cells
+
+import "testing"
+
+func TestRow(t *testing.T) {
+	tests := []struct {
+		row  string
+		want string
+	}{
+		// s is a solid shape, d a dashed arrow body, h an arrow head and . text
+		{"──┤ GET ├──▶", "sss.....sssh"},
+		{"◀-┤ Result ├--", "hds........sdd"},
+		{"--┤ a-b ├-->", "dds.....sddh"},
+		{"<---┘", "hddds"},
+		{"¦ -x- <- ->", "s.........."},
+		{"│ a <- b │", "s........s"},
+		{"- a->b", "......"},
+		{"──>", "ssh"},
+	}
+	for _, test := range tests {
+		var got []rune
+		for _, shape := range Row([]rune(test.row)) {
+			switch {
+			case shape == nil:
+				got = append(got, '.')
+			case shape.Head != NoHead:
+				got = append(got, 'h')
+			case shape.Segments[0].Style == Dashed && len(shape.Segments) == 1 && shape.Segments[0].From == Left:
+				got = append(got, 'd')
+			default:
+				got = append(got, 's')
+			}
+		}
+		if string(got) != test.want {
+			t.Errorf("TestRow => input: %q, got %q, want %q", test.row, string(got), test.want)
+		}
+	}
+}
+
+func TestGrid(t *testing.T) {
+	rows, width := Grid("ab\n┌─┐\n")
+	if len(rows) != 3 || width != 3 || string(rows[1]) != "┌─┐" {
+		t.Errorf("TestGrid => got %q, width %d", rows, width)
+	}
+}
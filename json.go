@@ -0,0 +1,88 @@
+package sequencediagram
+
+import "encoding/json"
+
+// jsonDiagram is the JSON representation of a sequence diagram
+type jsonDiagram struct {
+	Title        string            `json:"title,omitempty"`
+	Participants []jsonParticipant `json:"participants"`
+	Messages     []jsonMessage     `json:"messages"`
+}
+
+type jsonParticipant struct {
+	Name  string `json:"name"`
+	Group string `json:"group,omitempty"`
+}
+
+type jsonMessage struct {
+	Type string `json:"type"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	// Participant is the node of a note or participant declaration
+	Participant string `json:"participant,omitempty"`
+	Side        string `json:"side,omitempty"`
+	Text        string `json:"text,omitempty"`
+	Dashed      bool   `json:"dashed,omitempty"`
+	Open        bool   `json:"open,omitempty"`
+	Order       *int   `json:"order,omitempty"`
+}
+
+// MarshalJSON returns the sequence diagram as a JSON object with the title,
+// the ordered participants (with their group) and the messages. The type of
+// each message is one of title, participant, message, note, box, end box,
+// divider, loop or end.
+func (sd *Diagram) MarshalJSON() ([]byte, error) {
+	jd := jsonDiagram{Participants: []jsonParticipant{}, Messages: []jsonMessage{}}
+	jd.Title, _ = sd.lastTitle()
+	for _, run := range sd.groupedNodes() {
+		for _, node := range run.nodes {
+			p := jsonParticipant{Name: node.Name}
+			if run.group != nil {
+				p.Group = run.group.Name
+			}
+			jd.Participants = append(jd.Participants, p)
+		}
+	}
+	for _, message := range sd.messages {
+		var m jsonMessage
+		switch message := message.(type) {
+		case Title:
+			m = jsonMessage{Type: "title", Text: message.Msg}
+		case Participant:
+			m = jsonMessage{Type: "participant", Participant: message.Self.Name}
+			if message.Ranked {
+				rank := message.Rank
+				m.Order = &rank
+			}
+		case SelfMessage:
+			m = jsonMessage{Type: "message", From: message.Self.Name, To: message.Self.Name, Text: message.Msg,
+				Dashed: message.AltArrowBody, Open: message.AltArrowEnd}
+		case ForwardMessage:
+			m = jsonMessage{Type: "message", From: message.From.Name, To: message.To.Name, Text: message.Msg,
+				Dashed: message.AltArrowBody, Open: message.AltArrowEnd}
+		case BackwardMessage:
+			m = jsonMessage{Type: "message", From: message.From.Name, To: message.To.Name, Text: message.Msg,
+				Dashed: message.AltArrowBody, Open: message.AltArrowEnd}
+		case Note:
+			side := "left"
+			if message.Side == Right {
+				side = "right"
+			}
+			m = jsonMessage{Type: "note", Participant: message.Node.Name, Side: side, Text: message.Msg}
+		case Box:
+			m = jsonMessage{Type: "box", Text: message.Group.Name}
+		case EndBox:
+			m = jsonMessage{Type: "end box"}
+		case Divider:
+			m = jsonMessage{Type: "divider", Text: message.Msg}
+		case Loop:
+			m = jsonMessage{Type: "loop", Text: message.Msg}
+		case EndLoop:
+			m = jsonMessage{Type: "end"}
+		default:
+			continue
+		}
+		jd.Messages = append(jd.Messages, m)
+	}
+	return json.Marshal(jd)
+}
@@ -0,0 +1,47 @@
+package sequencediagram
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"participant a", `{"participants":[{"name":"a"}],"messages":[{"type":"participant","participant":"a"}]}`},
+		{"title T\nparticipant b order 1\na->b:x\nb-->>a:y\na->a:z", `{"title":"T","participants":[{"name":"a"},{"name":"b"}],"messages":[` +
+			`{"type":"title","text":"T"},{"type":"participant","participant":"b","order":1},` +
+			`{"type":"message","from":"a","to":"b","text":"x"},{"type":"message","from":"b","to":"a","text":"y","dashed":true,"open":true},` +
+			`{"type":"message","from":"a","to":"a","text":"z"}]}`},
+		{"box \"Backend\"\nparticipant s\nend box\nnote left of s:n\n== d ==\nloop l\nc->s:x\nend", `{"participants":[{"name":"s","group":"Backend"},{"name":"c"}],"messages":[` +
+			`{"type":"box","text":"Backend"},{"type":"participant","participant":"s"},{"type":"end box"},` +
+			`{"type":"note","participant":"s","side":"left","text":"n"},{"type":"divider","text":"d"},` +
+			`{"type":"loop","text":"l"},{"type":"message","from":"c","to":"s","text":"x"},{"type":"end"}]}`},
+	}
+	for _, test := range tests {
+		sd, err := ParseFromText(test.text)
+		if err != nil {
+			t.Fatalf("error parsing sequence diagram: %v", err)
+		}
+		b, err := json.Marshal(sd)
+		if err != nil {
+			t.Errorf("TestMarshalJSON => input: %q, got error: %v", test.text, err)
+			continue
+		}
+		if string(b) != test.want {
+			t.Errorf("TestMarshalJSON => input: %q, got:\n%s\nwant:\n%s", test.text, b, test.want)
+		}
+	}
+}
+
+func TestMarshalJSONEmpty(t *testing.T) {
+	b, err := json.Marshal(&Diagram{})
+	if err != nil {
+		t.Fatalf("TestMarshalJSONEmpty => got error: %v", err)
+	}
+	if want := `{"participants":[],"messages":[]}`; string(b) != want {
+		t.Errorf("TestMarshalJSONEmpty => got %s, want %s", b, want)
+	}
+}
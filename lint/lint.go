@@ -0,0 +1,269 @@
+// package lint checks sequence diagrams for likely mistakes
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Laugusti/sequencediagram"
+)
+
+// Finding is a problem found by a rule. Line is the line of the message in
+// Diagram.String(), which is its line in the text given to ParseFromText.
+type Finding struct {
+	Line    int
+	Rule    string
+	Message string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("Line %d: %s (%s)", f.Line, f.Message, f.Rule)
+}
+
+// Config selects the rules that are checked
+type Config struct {
+	// Rules enables or disables rules by name, rules that are not listed
+	// keep their default
+	Rules map[string]bool `json:"rules"`
+	// MaxLabelLength is the longest label line allowed by the long-label rule
+	MaxLabelLength int `json:"maxLabelLength"`
+}
+
+// defaultMaxLabelLength is the MaxLabelLength of DefaultConfig
+const defaultMaxLabelLength = 40
+
+type rule struct {
+	name    string
+	enabled bool
+	check   func(sd *sequencediagram.Diagram, cfg Config) []Finding
+}
+
+var rules = []rule{
+	{"unused-participant", true, unusedParticipants},
+	{"implicit-participant", false, implicitParticipants},
+	{"missing-response", true, missingResponses},
+	{"early-response", true, earlyResponses},
+	{"duplicate-title", true, duplicateTitles},
+	{"long-label", true, longLabels},
+}
+
+// RuleNames returns the names of all rules
+func RuleNames() []string {
+	var names []string
+	for _, r := range rules {
+		names = append(names, r.name)
+	}
+	return names
+}
+
+// DefaultConfig enables every rule except implicit-participant and allows
+// labels of 40 characters
+func DefaultConfig() Config {
+	cfg := Config{Rules: make(map[string]bool), MaxLabelLength: defaultMaxLabelLength}
+	for _, r := range rules {
+		cfg.Rules[r.name] = r.enabled
+	}
+	return cfg
+}
+
+// LoadConfig reads a JSON config file, for example
+// {"rules": {"implicit-participant": true}, "maxLabelLength": 60}. Settings
+// that are not in the file keep their default.
+func LoadConfig(filename string) (Config, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return Config{}, err
+	}
+	var file Config
+	if err := json.Unmarshal(b, &file); err != nil {
+		return Config{}, fmt.Errorf("%s: %v", filename, err)
+	}
+	cfg := DefaultConfig()
+	for name, enabled := range file.Rules {
+		if _, ok := cfg.Rules[name]; !ok {
+			return Config{}, fmt.Errorf("%s: unknown rule %s", filename, name)
+		}
+		cfg.Rules[name] = enabled
+	}
+	if file.MaxLabelLength > 0 {
+		cfg.MaxLabelLength = file.MaxLabelLength
+	}
+	return cfg, nil
+}
+
+// Lint checks the sequence diagram with the enabled rules and returns the
+// findings ordered by line
+func Lint(sd *sequencediagram.Diagram, cfg Config) []Finding {
+	var findings []Finding
+	for _, r := range rules {
+		enabled, ok := cfg.Rules[r.name]
+		if !ok {
+			enabled = r.enabled
+		}
+		if enabled {
+			findings = append(findings, r.check(sd, cfg)...)
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Line < findings[j].Line })
+	return findings
+}
+
+// participants returns the nodes that a message uses, other than declaring them
+func participants(message sequencediagram.Message) []*sequencediagram.Node {
+	switch m := message.(type) {
+	case sequencediagram.SelfMessage:
+		return []*sequencediagram.Node{m.Self}
+	case sequencediagram.ForwardMessage:
+		return []*sequencediagram.Node{m.From, m.To}
+	case sequencediagram.BackwardMessage:
+		return []*sequencediagram.Node{m.From, m.To}
+	case sequencediagram.Note:
+		return []*sequencediagram.Node{m.Node}
+	}
+	return nil
+}
+
+func unusedParticipants(sd *sequencediagram.Diagram, cfg Config) []Finding {
+	used := make(map[*sequencediagram.Node]bool)
+	for _, message := range sd.Messages() {
+		for _, node := range participants(message) {
+			used[node] = true
+		}
+	}
+	var findings []Finding
+	reported := make(map[*sequencediagram.Node]bool)
+	for i, message := range sd.Messages() {
+		if p, ok := message.(sequencediagram.Participant); ok && !used[p.Self] && !reported[p.Self] {
+			reported[p.Self] = true
+			findings = append(findings, Finding{i + 1, "unused-participant", fmt.Sprintf("Participant %s is declared but never used.", p.Self.Name)})
+		}
+	}
+	return findings
+}
+
+func implicitParticipants(sd *sequencediagram.Diagram, cfg Config) []Finding {
+	declared := make(map[*sequencediagram.Node]bool)
+	for _, message := range sd.Messages() {
+		if p, ok := message.(sequencediagram.Participant); ok {
+			declared[p.Self] = true
+		}
+	}
+	var findings []Finding
+	for i, message := range sd.Messages() {
+		for _, node := range participants(message) {
+			if !declared[node] {
+				declared[node] = true
+				findings = append(findings, Finding{i + 1, "implicit-participant", fmt.Sprintf("Participant %s is not declared.", node.Name)})
+			}
+		}
+	}
+	return findings
+}
+
+// exchange is a request (solid arrow) or response (dashed arrow) between two nodes
+type exchange struct {
+	line     int
+	from, to *sequencediagram.Node
+	response bool
+}
+
+func exchanges(sd *sequencediagram.Diagram) []exchange {
+	var result []exchange
+	for i, message := range sd.Messages() {
+		switch m := message.(type) {
+		case sequencediagram.ForwardMessage:
+			result = append(result, exchange{i + 1, m.From, m.To, m.AltArrowBody})
+		case sequencediagram.BackwardMessage:
+			result = append(result, exchange{i + 1, m.From, m.To, m.AltArrowBody})
+		}
+	}
+	return result
+}
+
+// matchExchanges pairs each response with the latest unanswered request in
+// the other direction and returns the unanswered requests and the responses
+// without an earlier request
+func matchExchanges(sd *sequencediagram.Diagram) ([]exchange, []exchange) {
+	type pair struct{ from, to *sequencediagram.Node }
+	pending := make(map[pair][]exchange)
+	var unanswered, unrequested []exchange
+	for _, e := range exchanges(sd) {
+		if !e.response {
+			pending[pair{e.from, e.to}] = append(pending[pair{e.from, e.to}], e)
+			continue
+		}
+		requests := pending[pair{e.to, e.from}]
+		if len(requests) == 0 {
+			unrequested = append(unrequested, e)
+			continue
+		}
+		pending[pair{e.to, e.from}] = requests[:len(requests)-1]
+	}
+	for _, requests := range pending {
+		unanswered = append(unanswered, requests...)
+	}
+	sort.Slice(unanswered, func(i, j int) bool { return unanswered[i].line < unanswered[j].line })
+	return unanswered, unrequested
+}
+
+func missingResponses(sd *sequencediagram.Diagram, cfg Config) []Finding {
+	unanswered, _ := matchExchanges(sd)
+	var findings []Finding
+	for _, e := range unanswered {
+		findings = append(findings, Finding{e.line, "missing-response", fmt.Sprintf("Request from %s to %s has no response.", e.from.Name, e.to.Name)})
+	}
+	return findings
+}
+
+func earlyResponses(sd *sequencediagram.Diagram, cfg Config) []Finding {
+	_, unrequested := matchExchanges(sd)
+	var findings []Finding
+	for _, r := range unrequested {
+		// only responses to a request that comes later are reported
+		for _, e := range exchanges(sd) {
+			if !e.response && e.line > r.line && e.from == r.to && e.to == r.from {
+				findings = append(findings, Finding{r.line, "early-response",
+					fmt.Sprintf("Response from %s to %s is sent before its request on line %d.", r.from.Name, r.to.Name, e.line)})
+				break
+			}
+		}
+	}
+	return findings
+}
+
+func duplicateTitles(sd *sequencediagram.Diagram, cfg Config) []Finding {
+	var findings []Finding
+	first := 0
+	for i, message := range sd.Messages() {
+		if _, ok := message.(sequencediagram.Title); !ok {
+			continue
+		}
+		if first == 0 {
+			first = i + 1
+			continue
+		}
+		findings = append(findings, Finding{i + 1, "duplicate-title", fmt.Sprintf("Title was already set on line %d, only the last title is used.", first)})
+	}
+	return findings
+}
+
+func longLabels(sd *sequencediagram.Diagram, cfg Config) []Finding {
+	max := cfg.MaxLabelLength
+	if max <= 0 {
+		max = defaultMaxLabelLength
+	}
+	var findings []Finding
+	for i, message := range sd.Messages() {
+		for _, line := range strings.Split(message.MessageText(), "\\n") {
+			if n := utf8.RuneCountInString(line); n > max {
+				findings = append(findings, Finding{i + 1, "long-label", fmt.Sprintf("Label is %d characters long, the maximum is %d.", n, max)})
+				break
+			}
+		}
+	}
+	return findings
+}
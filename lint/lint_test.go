@@ -0,0 +1,90 @@
+package lint
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Laugusti/sequencediagram"
+)
+
+func TestLint(t *testing.T) {
+	all := DefaultConfig()
+	for name := range all.Rules {
+		all.Rules[name] = true
+	}
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"participant A\nparticipant B\nA->B:x\nB-->A:y", nil},
+		{"participant A\nparticipant B\nparticipant C\nA->B:x\nB-->A:y", []string{"Line 3: Participant C is declared but never used. (unused-participant)"}},
+		{"participant A\nA->B:x\nB-->A:y", []string{"Line 2: Participant B is not declared. (implicit-participant)"}},
+		{"participant A\nparticipant B\nA->B:x\nA->B:y\nB-->A:z", []string{"Line 3: Request from A to B has no response. (missing-response)"}},
+		{"participant A\nparticipant B\nB-->A:y\nA->B:x", []string{
+			"Line 3: Response from B to A is sent before its request on line 4. (early-response)",
+			"Line 4: Request from A to B has no response. (missing-response)",
+		}},
+		{"participant A\nparticipant B\nB-->A:y", nil},
+		{"participant A\nparticipant B\nA->B:x\nA->A:self\nB->>A:y\nB-->A:z", []string{"Line 5: Request from B to A has no response. (missing-response)"}},
+		{"title One\nparticipant A\ntitle Two\nnote right of A:n", []string{"Line 3: Title was already set on line 1, only the last title is used. (duplicate-title)"}},
+		{"participant A\nA->A:" + strings.Repeat("x", 41) + "\nnote left of A:short\\n" + strings.Repeat("y", 40), []string{"Line 2: Label is 41 characters long, the maximum is 40. (long-label)"}},
+	}
+	for _, test := range tests {
+		sd, err := sequencediagram.ParseFromText(test.text)
+		if err != nil {
+			t.Fatalf("error parsing sequence diagram: %v", err)
+		}
+		var got []string
+		for _, f := range Lint(sd, all) {
+			got = append(got, f.String())
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("TestLint => input: %q\ngot:  %q\nwant: %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestLintConfig(t *testing.T) {
+	sd, err := sequencediagram.ParseFromText("title One\ntitle Two\nA->B:a long label")
+	if err != nil {
+		t.Fatalf("error parsing sequence diagram: %v", err)
+	}
+	dir := t.TempDir()
+	tests := []struct {
+		config string
+		want   []string
+		err    string
+	}{
+		{"{}", []string{"duplicate-title", "missing-response"}, ""},
+		{`{"rules": {"implicit-participant": true, "duplicate-title": false}}`, []string{"implicit-participant", "implicit-participant", "missing-response"}, ""},
+		{`{"maxLabelLength": 5}`, []string{"duplicate-title", "missing-response", "long-label"}, ""},
+		{`{"rules": {"no-such-rule": true}}`, nil, "unknown rule no-such-rule"},
+		{`{"rules": []}`, nil, "cannot unmarshal"},
+	}
+	for _, test := range tests {
+		filename := filepath.Join(dir, "lint.json")
+		if err := ioutil.WriteFile(filename, []byte(test.config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := LoadConfig(filename)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("TestLintConfig => config: %s, got error %v, want %q", test.config, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestLintConfig => config: %s, unexpected error: %v", test.config, err)
+		}
+		var got []string
+		for _, f := range Lint(sd, cfg) {
+			got = append(got, f.Rule)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("TestLintConfig => config: %s\ngot:  %q\nwant: %q", test.config, got, test.want)
+		}
+	}
+}
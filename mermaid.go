@@ -0,0 +1,248 @@
+package sequencediagram
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// mermaidArrows maps the alternative arrow body and end to a Mermaid arrow
+var mermaidArrows = map[uniDirectionalMessage]string{
+	{false, false}: "->>",
+	{true, false}:  "-->>",
+	{false, true}:  "-)",
+	{true, true}:   "--)",
+}
+
+// Mermaid returns the sequence diagram in Mermaid syntax. Participants are
+// declared up front, inside their boxes, so Mermaid keeps the order of the
+// nodes. Mermaid has no dividers, so a divider is written as a note over all
+// participants after a "%% == label ==" comment, which ParseFromMermaid reads
+// back as the divider.
+func (sd *Diagram) Mermaid() string {
+	ids := participantIDs(sd.GetOrderedNodes())
+	lines := []string{"sequenceDiagram"}
+	if title, ok := sd.lastTitle(); ok {
+		lines = append(lines, "title "+mermaidText(title))
+	}
+	declare := func(node *Node) string {
+		if ids[node] == node.Name {
+			return "participant " + node.Name
+		}
+		return "participant " + ids[node] + " as " + mermaidText(node.Name)
+	}
+	for _, nodes := range sd.groupedNodes() {
+		if nodes.group == nil {
+			lines = append(lines, declare(nodes.nodes[0]))
+			continue
+		}
+		lines = append(lines, "box "+mermaidText(nodes.group.Name))
+		for _, node := range nodes.nodes {
+			lines = append(lines, declare(node))
+		}
+		lines = append(lines, "end")
+	}
+	// boxes without participants are not in the runs of nodes
+	for _, group := range sd.groups {
+		if len(group.Nodes) == 0 {
+			lines = append(lines, "box "+mermaidText(group.Name), "end")
+		}
+	}
+	for _, message := range sd.messages {
+		switch message := message.(type) {
+		case SelfMessage:
+			lines = append(lines, ids[message.Self]+mermaidArrows[message.uniDirectionalMessage]+ids[message.Self]+": "+mermaidText(message.Msg))
+		case ForwardMessage:
+			lines = append(lines, ids[message.From]+mermaidArrows[message.uniDirectionalMessage]+ids[message.To]+": "+mermaidText(message.Msg))
+		case BackwardMessage:
+			lines = append(lines, ids[message.From]+mermaidArrows[message.uniDirectionalMessage]+ids[message.To]+": "+mermaidText(message.Msg))
+		case Note:
+			side := "left"
+			if message.Side == Right {
+				side = "right"
+			}
+			lines = append(lines, "Note "+side+" of "+ids[message.Node]+": "+mermaidText(message.Msg))
+		case Divider:
+			lines = append(lines, "%% == "+mermaidText(message.Msg)+" ==")
+			nodes := sd.GetOrderedNodes()
+			if len(nodes) == 0 {
+				continue
+			}
+			over := ids[nodes[0]]
+			if len(nodes) > 1 {
+				over += "," + ids[nodes[len(nodes)-1]]
+			}
+			lines = append(lines, "Note over "+over+": "+mermaidText(message.Msg))
+		case Loop:
+			lines = append(lines, "loop "+mermaidText(message.Msg))
+		case EndLoop:
+			lines = append(lines, "end")
+		case Box, EndBox:
+			// declared with the participants above
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// mermaidText escapes the characters Mermaid treats specially and replaces
+// "\n" with a line break
+func mermaidText(s string) string {
+	s = strings.NewReplacer("#", "#35;", ";", "#59;").Replace(s)
+	return strings.Replace(s, `\n`, "<br/>", -1)
+}
+
+var (
+	mermaidHeaderPattern      = regexp.MustCompile("^sequenceDiagram$")
+	mermaidTitlePattern       = regexp.MustCompile("^title:?\\s+(.+)$")
+	mermaidParticipantPattern = regexp.MustCompile("^(?:participant|actor)\\s+(.+?)(?:\\s+as\\s+(.+))?$")
+	mermaidBoxPattern         = regexp.MustCompile("^box\\s+(.+)$")
+	mermaidBoxColorPattern    = regexp.MustCompile("^(?:transparent|rgba?\\([^)]*\\))\\s+")
+	mermaidMessagePattern     = regexp.MustCompile("^([^-<>:+]+?)\\s*(-->>|->>|--\\)|-\\)|-->|->)\\s*([^-<>:+]+?)\\s*:(.*)$")
+	mermaidLoopPattern        = regexp.MustCompile("^loop\\s+(.+)$")
+	mermaidNotePattern        = regexp.MustCompile("^[Nn]ote\\s+(left of|right of|over)\\s+([^:]+?)\\s*:(.*)$")
+	mermaidStatementPattern   = regexp.MustCompile("^(autonumber|loop|alt|else|opt|par|and|critical|option|break|rect|activate|deactivate|create|destroy|links?|properties|details|end)\\b")
+	mermaidEntityPattern      = regexp.MustCompile("#([0-9]+);")
+	mermaidBreakPattern       = regexp.MustCompile("(?i)<br\\s*/?>")
+	mermaidDividerPattern     = regexp.MustCompile("^%%\\s*== (.+) ==$")
+)
+
+// ParseFromMermaid parses a Mermaid sequenceDiagram into a sequence diagram.
+// Arrows without an arrow head are read as arrows with one and notes over
+// participants are placed right of the first participant. A "%% == label =="
+// comment is read as a divider, the note over participants with the same label
+// that follows it is skipped. Other Mermaid statements (alternatives,
+// activations, ...) are reported as errors.
+func ParseFromMermaid(s string) (*Diagram, error) {
+	sd := &Diagram{}
+	// participant ids to node names from "participant X as Y"
+	aliases := make(map[string]string)
+	node := func(id string) *Node {
+		if name, ok := aliases[id]; ok {
+			return sd.getOrCreateNode(name)
+		}
+		return sd.getOrCreateNode(id)
+	}
+	var header bool
+	var group *Group
+	var groupLine int
+	// line numbers of the loops that are not ended yet
+	var loopLines []int
+	// the label of the last divider comment, its note is skipped
+	var divider string
+	for i, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if header && mermaidDividerPattern.MatchString(line) {
+			divider = mermaidDividerPattern.FindStringSubmatch(line)[1]
+			sd.messages = append(sd.messages, Divider{simpleMessage{mermaidUnescape(divider)}})
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "%%") {
+			continue
+		}
+		if note := mermaidNotePattern.FindStringSubmatch(line); divider != "" && note != nil && note[1] == "over" && strings.TrimSpace(note[3]) == divider {
+			divider = ""
+			continue
+		}
+		divider = ""
+		if !header {
+			if !mermaidHeaderPattern.MatchString(line) {
+				return nil, fmt.Errorf("Line %d: Expected sequenceDiagram.", i+1)
+			}
+			header = true
+			continue
+		}
+		switch {
+		case group != nil && line == "end":
+			sd.messages = append(sd.messages, EndBox{noMessage{}})
+			group = nil
+		case mermaidParticipantPattern.MatchString(line):
+			match := mermaidParticipantPattern.FindStringSubmatch(line)
+			name := match[1]
+			if match[2] != "" {
+				name = mermaidUnescape(match[2])
+				aliases[match[1]] = name
+			}
+			if _, ok := sd.nodes[name]; ok && group != nil {
+				return nil, fmt.Errorf("Line %d: Participant %s already declared outside of box.", i+1, name)
+			}
+			n := sd.getOrCreateNode(name)
+			if group != nil {
+				group.Nodes = append(group.Nodes, n)
+			}
+			sd.messages = append(sd.messages, Participant{Self: n})
+		case group != nil:
+			return nil, fmt.Errorf("Line %d: Only participants can be declared in a box.", i+1)
+		case mermaidBoxPattern.MatchString(line):
+			name := mermaidBoxColorPattern.ReplaceAllString(mermaidBoxPattern.FindStringSubmatch(line)[1], "")
+			group = &Group{Name: mermaidUnescape(name)}
+			groupLine = i + 1
+			sd.groups = append(sd.groups, group)
+			sd.messages = append(sd.messages, Box{group, noMessage{}})
+		case mermaidTitlePattern.MatchString(line):
+			title := mermaidUnescape(mermaidTitlePattern.FindStringSubmatch(line)[1])
+			sd.messages = append(sd.messages, Title{simpleMessage{title}})
+		case mermaidMessagePattern.MatchString(line):
+			match := mermaidMessagePattern.FindStringSubmatch(line)
+			from, to := node(match[1]), node(match[3])
+			udm := uniDirectionalMessage{
+				AltArrowBody: strings.HasPrefix(match[2], "--"),
+				AltArrowEnd:  strings.HasSuffix(match[2], ")"),
+			}
+			msg := mermaidUnescape(strings.TrimSpace(match[4]))
+			sd.messages = append(sd.messages, classifyMessage(from, to, simpleMessage{msg}, udm))
+		case mermaidNotePattern.MatchString(line):
+			match := mermaidNotePattern.FindStringSubmatch(line)
+			side := Right
+			if match[1] == "left of" {
+				side = Left
+			}
+			// a note over two participants is placed right of the first one
+			ids := strings.Split(match[2], ",")
+			if len(ids) > 2 || (len(ids) == 2 && match[1] != "over") {
+				return nil, fmt.Errorf("Line %d: Note must be placed next to one participant.", i+1)
+			}
+			n := node(strings.TrimSpace(ids[0]))
+			if len(ids) == 2 {
+				if other := node(strings.TrimSpace(ids[1])); other.Order < n.Order {
+					n = other
+				}
+			}
+			msg := mermaidUnescape(strings.TrimSpace(match[3]))
+			sd.messages = append(sd.messages, Note{n, side, simpleMessage{msg}})
+		case mermaidLoopPattern.MatchString(line):
+			loop := mermaidUnescape(mermaidLoopPattern.FindStringSubmatch(line)[1])
+			loopLines = append(loopLines, i+1)
+			sd.messages = append(sd.messages, Loop{simpleMessage{loop}})
+		case line == "end" && len(loopLines) > 0:
+			loopLines = loopLines[:len(loopLines)-1]
+			sd.messages = append(sd.messages, EndLoop{noMessage{}})
+		case mermaidStatementPattern.MatchString(line):
+			return nil, fmt.Errorf("Line %d: Unsupported statement %s.", i+1, mermaidStatementPattern.FindString(line))
+		default:
+			return nil, fmt.Errorf("Line %d: Syntax error.", i+1)
+		}
+	}
+	if !header {
+		return nil, fmt.Errorf("Line 1: Expected sequenceDiagram.")
+	}
+	if group != nil {
+		return nil, fmt.Errorf("Line %d: Box is never ended.", groupLine)
+	}
+	if len(loopLines) > 0 {
+		return nil, fmt.Errorf("Line %d: Loop is never ended.", loopLines[len(loopLines)-1])
+	}
+	return sd, nil
+}
+
+// mermaidUnescape replaces entity codes and line breaks, the reverse of mermaidText
+func mermaidUnescape(s string) string {
+	s = mermaidBreakPattern.ReplaceAllString(s, `\n`)
+	return mermaidEntityPattern.ReplaceAllStringFunc(s, func(entity string) string {
+		code, err := strconv.Atoi(mermaidEntityPattern.FindStringSubmatch(entity)[1])
+		if err != nil {
+			return entity
+		}
+		return string(rune(code))
+	})
+}
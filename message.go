@@ -44,13 +44,21 @@ func (t Title) String() string {
 	return "title " + t.Msg
 }
 
+// Participant declares a node. If Ranked is set, the node is ordered by Rank
+// instead of by first appearance. Build it with keyed fields, as fields may be
+// added.
 type Participant struct {
-	Self *Node
+	Self   *Node
+	Rank   int
+	Ranked bool
 	noMessage
 }
 
 func (p Participant) String() string {
-	return "participant " + p.Self.Name
+	if p.Ranked {
+		return fmt.Sprintf("participant %s order %d", participantName(p.Self.Name), p.Rank)
+	}
+	return "participant " + participantName(p.Self.Name)
 }
 
 type SelfMessage struct {
@@ -105,3 +113,47 @@ func (n Note) String() string {
 	}
 	return fmt.Sprintf("note %s of %s:%s", side, n.Node.Name, n.Msg)
 }
+
+type Box struct {
+	Group *Group
+	noMessage
+}
+
+func (b Box) String() string {
+	return `box "` + b.Group.Name + `"`
+}
+
+type EndBox struct {
+	noMessage
+}
+
+func (eb EndBox) String() string {
+	return "end box"
+}
+
+// Divider separates the sequence diagram into sections
+type Divider struct {
+	simpleMessage
+}
+
+func (d Divider) String() string {
+	return "== " + d.Msg + " =="
+}
+
+// Loop starts a block of messages that are repeated, the block is closed by
+// an EndLoop
+type Loop struct {
+	simpleMessage
+}
+
+func (l Loop) String() string {
+	return "loop " + l.Msg
+}
+
+type EndLoop struct {
+	noMessage
+}
+
+func (el EndLoop) String() string {
+	return "end"
+}
@@ -3,14 +3,22 @@ package sequencediagram
 import (
 	"fmt"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 )
 
 var (
 	titlePattern       = regexp.MustCompile("^title (.+)$")
 	participantPattern = regexp.MustCompile("^participant (.+)$")
+	orderPattern       = regexp.MustCompile("^(.+) order (-?[0-9]+)$")
 	messagePattern     = regexp.MustCompile("^.+->.+:.+$")
 	notePattern        = regexp.MustCompile("^note (right|left) of (.+):(.+)$")
+	boxPattern         = regexp.MustCompile(`^box "(.+)"$`)
+	endBoxPattern      = regexp.MustCompile("^end box$")
+	dividerPattern     = regexp.MustCompile("^== (.+) ==$")
+	loopPattern        = regexp.MustCompile("^loop (.+)$")
+	endLoopPattern     = regexp.MustCompile("^end$")
 )
 
 var arrowRegex = regexp.MustCompile("--?>>?")
@@ -18,14 +26,42 @@ var arrowRegex = regexp.MustCompile("--?>>?")
 func ParseFromText(s string) (*Diagram, error) {
 	lines := strings.Split(s, "\n")
 	sd := &Diagram{}
+	// group and line number of the box currently being declared
+	var group *Group
+	var groupLine int
+	// line numbers of the loops that are not ended yet
+	var loopLines []int
 	for i, line := range lines {
 		switch {
+		case group != nil && endBoxPattern.MatchString(line):
+			sd.messages = append(sd.messages, EndBox{noMessage{}})
+			group = nil
+		case group != nil && participantPattern.MatchString(line):
+			name, rank, ranked := parseParticipant(participantPattern.FindStringSubmatch(line)[1])
+			// only new participants can join a group so its members stay contiguous
+			if _, ok := sd.nodes[name]; ok {
+				return nil, fmt.Errorf("Line %d: Participant %s already declared outside of box.", i+1, name)
+			}
+			node := sd.getOrCreateNode(name)
+			group.Nodes = append(group.Nodes, node)
+			sd.messages = append(sd.messages, Participant{node, rank, ranked, noMessage{}})
+		case group != nil:
+			return nil, fmt.Errorf("Line %d: Only participants can be declared in a box.", i+1)
+		case boxPattern.MatchString(line):
+			group = &Group{Name: boxPattern.FindStringSubmatch(line)[1]}
+			groupLine = i + 1
+			sd.groups = append(sd.groups, group)
+			sd.messages = append(sd.messages, Box{group, noMessage{}})
 		case titlePattern.MatchString(line):
 			title := titlePattern.FindStringSubmatch(line)[1]
 			sd.messages = append(sd.messages, Title{simpleMessage{title}})
 		case participantPattern.MatchString(line):
-			node := sd.getOrCreateNode(participantPattern.FindStringSubmatch(line)[1])
-			sd.messages = append(sd.messages, Participant{node, noMessage{}})
+			name, rank, ranked := parseParticipant(participantPattern.FindStringSubmatch(line)[1])
+			node := sd.getOrCreateNode(name)
+			sd.messages = append(sd.messages, Participant{node, rank, ranked, noMessage{}})
+		case dividerPattern.MatchString(line):
+			divider := dividerPattern.FindStringSubmatch(line)[1]
+			sd.messages = append(sd.messages, Divider{simpleMessage{divider}})
 		case messagePattern.MatchString(line):
 			arrow := arrowRegex.FindString(line)
 			message := regexp.MustCompile("^(.+)" + arrow + "(.+):(.+)$").FindStringSubmatch(line)[1:]
@@ -37,24 +73,88 @@ func ParseFromText(s string) (*Diagram, error) {
 			note := notePattern.FindStringSubmatch(line)[1:]
 			node := sd.getOrCreateNode(note[1])
 			sd.messages = append(sd.messages, createNote(node, note[0], note[2]))
+		case loopPattern.MatchString(line):
+			loop := loopPattern.FindStringSubmatch(line)[1]
+			loopLines = append(loopLines, i+1)
+			sd.messages = append(sd.messages, Loop{simpleMessage{loop}})
+		case endLoopPattern.MatchString(line):
+			if len(loopLines) == 0 {
+				return nil, fmt.Errorf("Line %d: End without loop.", i+1)
+			}
+			loopLines = loopLines[:len(loopLines)-1]
+			sd.messages = append(sd.messages, EndLoop{noMessage{}})
 		default:
 			return nil, fmt.Errorf("Line %d: Syntax error.", i+1)
 		}
 	}
+	if group != nil {
+		return nil, fmt.Errorf("Line %d: Box is never ended.", groupLine)
+	}
+	if len(loopLines) > 0 {
+		return nil, fmt.Errorf("Line %d: Loop is never ended.", loopLines[len(loopLines)-1])
+	}
+	sd.applyRanks()
 	return sd, nil
 }
 
+// splits a participant declaration into the name and optional explicit order.
+// A name in double quotes is read as is, so it can end in " order <n>".
+func parseParticipant(s string) (string, int, bool) {
+	name, rank, ranked := s, 0, false
+	if match := orderPattern.FindStringSubmatch(s); match != nil {
+		if r, err := strconv.Atoi(match[2]); err == nil {
+			name, rank, ranked = match[1], r, true
+		}
+	}
+	if len(name) > 2 && strings.HasPrefix(name, `"`) && strings.HasSuffix(name, `"`) {
+		name = name[1 : len(name)-1]
+	}
+	return name, rank, ranked
+}
+
+// participantName is the name as written in a participant declaration, quoted
+// if parseParticipant would not read it back as is
+func participantName(name string) string {
+	if orderPattern.MatchString(name) || len(name) > 1 && strings.HasPrefix(name, `"`) && strings.HasSuffix(name, `"`) {
+		return `"` + name + `"`
+	}
+	return name
+}
+
+// applyRanks orders the nodes by the explicit order of their participant
+// declaration. Nodes without an explicit order have order 0 and ties keep the
+// order of first appearance.
+func (sd *Diagram) applyRanks() {
+	ranks := make(map[*Node]int)
+	for _, message := range sd.messages {
+		if p, ok := message.(Participant); ok && p.Ranked {
+			ranks[p.Self] = p.Rank
+		}
+	}
+	if len(ranks) == 0 {
+		return
+	}
+	nodes := sd.GetOrderedNodes()
+	sort.SliceStable(nodes, func(i, j int) bool { return ranks[nodes[i]] < ranks[nodes[j]] })
+	sd.setOrder(nodes)
+}
+
 // creates a self/from/to message
 func createMessage(from, to *Node, arrowType, msg string) Message {
 	altArrowBody := strings.HasPrefix(arrowType, "--")
 	altArrowEnd := strings.HasSuffix(arrowType, ">>")
+	return classifyMessage(from, to, simpleMessage{msg}, uniDirectionalMessage{altArrowBody, altArrowEnd})
+}
+
+// creates a self/from/to message depending on the current order of the nodes
+func classifyMessage(from, to *Node, sm simpleMessage, udm uniDirectionalMessage) Message {
 	switch {
 	case from.Order == to.Order:
-		return SelfMessage{from, simpleMessage{msg}, uniDirectionalMessage{altArrowBody, altArrowEnd}}
+		return SelfMessage{from, sm, udm}
 	case from.Order < to.Order:
-		return ForwardMessage{from, to, simpleMessage{msg}, uniDirectionalMessage{altArrowBody, altArrowEnd}}
+		return ForwardMessage{from, to, sm, udm}
 	case from.Order > to.Order:
-		return BackwardMessage{from, to, simpleMessage{msg}, uniDirectionalMessage{altArrowBody, altArrowEnd}}
+		return BackwardMessage{from, to, sm, udm}
 	}
 	return nil
 }
@@ -26,6 +26,25 @@ func TestParseFromText(t *testing.T) {
 		{"note right of alice:msg", true},
 		{"note left of alice:msg", true},
 		{"note above alice:msg", false},
+		{"box \"Backend\"\nparticipant server\nparticipant db\nend box", true},
+		{"box \"Backend\"\nend box\nbox \"Frontend\"\nparticipant web\nend box", true},
+		{"box \"Backend\"\nparticipant server", false},
+		{"end box", false},
+		{"box \"Backend\"\nbox \"Frontend\"\nend box\nend box", false},
+		{"box \"Backend\"\na->b:msg\nend box", false},
+		{"a->b:msg\nbox \"Backend\"\nparticipant b\nend box", false},
+		{"box Backend", false},
+		{"participant alice order 10", true},
+		{"participant alice order -2\nalice->bob:msg", true},
+		{"box \"Backend\"\nparticipant server order 1\nend box", true},
+		{"participant alice order ten", true},
+		{"== Section 1 ==", true},
+		{"==Section 1==", false},
+		{"loop every 5s\na->b:poll\nend", true},
+		{"loop outer\nloop inner\na->a:msg\nend\nend", true},
+		{"loop forever\na->b:poll", false},
+		{"end", false},
+		{"loop", false},
 	}
 	for _, test := range tests {
 		sd, err := ParseFromText(test.text)
@@ -52,6 +71,10 @@ func TestParseFromTextMessageType(t *testing.T) {
 		{"a->b:msg\nb-->>a:resp", messageTypes(ForwardMessage{}, BackwardMessage{})},
 		{"note right of a:msg", messageTypes(Note{})},
 		{"note left of a:msg", messageTypes(Note{})},
+		{"box \"b\"\nparticipant a\nend box", messageTypes(Box{}, Participant{}, EndBox{})},
+		{"a->b:msg\nparticipant a order 1", messageTypes(BackwardMessage{}, Participant{})},
+		{"b->a:msg\nparticipant a order -1", messageTypes(BackwardMessage{}, Participant{})},
+		{"== a->b:msg ==", messageTypes(Divider{})},
 	}
 
 	for _, test := range tests {
@@ -72,6 +95,97 @@ func TestParseFromTextMessageType(t *testing.T) {
 	}
 }
 
+func TestParseFromTextGroups(t *testing.T) {
+	text := "participant a\nbox \"Backend\"\nparticipant b\nparticipant c\nend box\nd->b:msg"
+	sd, err := ParseFromText(text)
+	if err != nil {
+		t.Fatalf("TestParseFromTextGroups => got parse error: %v", err)
+	}
+	groups := sd.Groups()
+	if len(groups) != 1 {
+		t.Fatalf("TestParseFromTextGroups => expected 1 group, got %d", len(groups))
+	}
+	if groups[0].Name != "Backend" {
+		t.Errorf("TestParseFromTextGroups => expected group name %q, got %q", "Backend", groups[0].Name)
+	}
+	var names []string
+	for _, node := range groups[0].Nodes {
+		names = append(names, node.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"b", "c"}) {
+		t.Errorf("TestParseFromTextGroups => expected group members [b c], got %v", names)
+	}
+	names = nil
+	for _, node := range sd.GetOrderedNodes() {
+		names = append(names, node.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"a", "b", "c", "d"}) {
+		t.Errorf("TestParseFromTextGroups => expected node order [a b c d], got %v", names)
+	}
+}
+
+func TestParseFromTextOrder(t *testing.T) {
+	tests := []struct {
+		text  string
+		order []string
+	}{
+		{"a->b:msg\nc->d:msg", []string{"a", "b", "c", "d"}},
+		{"a->b:msg\nc->d:msg\nparticipant a order 1", []string{"b", "c", "d", "a"}},
+		{"a->b:msg\nc->d:msg\nparticipant d order -1", []string{"d", "a", "b", "c"}},
+		{"participant a order 2\nparticipant b order 1\nparticipant c order 1", []string{"b", "c", "a"}},
+		{"participant x\nbox \"g\"\nparticipant a\nparticipant b order -1\nend box", []string{"b", "a", "x"}},
+		// quoted names can end in " order <n>"
+		{"a->Sort order 3:msg\nparticipant \"Sort order 3\"", []string{"a", "Sort order 3"}},
+		{"a->Sort order 3:msg\nparticipant \"Sort order 3\" order -1", []string{"Sort order 3", "a"}},
+		{"a->Sort:msg\nparticipant Sort order -3", []string{"Sort", "a"}},
+	}
+	for _, test := range tests {
+		sd, err := ParseFromText(test.text)
+		if err != nil {
+			t.Errorf("TestParseFromTextOrder => got parse error: %v", err)
+			continue
+		}
+		if got := nodeNames(sd.GetOrderedNodes()); !reflect.DeepEqual(got, test.order) {
+			t.Errorf("TestParseFromTextOrder => input: %q, expected node order %v, got %v", test.text, test.order, got)
+		}
+	}
+}
+
+func TestParticipantString(t *testing.T) {
+	tests := []struct {
+		text string
+		name string
+	}{
+		{"participant Sort", "Sort"},
+		{"participant Sort order 3", "Sort"},
+		{"participant \"Sort order 3\"", "Sort order 3"},
+		{"participant \"Sort order 3\" order 1", "Sort order 3"},
+		{"participant \"\"quoted\"\"", "\"quoted\""},
+		{"participant a \"b\"", "a \"b\""},
+	}
+	for _, test := range tests {
+		sd, err := ParseFromText(test.text)
+		if err != nil {
+			t.Errorf("TestParticipantString => got parse error: %v", err)
+			continue
+		}
+		if got := sd.GetOrderedNodes()[0].Name; got != test.name {
+			t.Errorf("TestParticipantString => input: %q, expected name %q, got %q", test.text, test.name, got)
+		}
+		// the declaration is written back the same way
+		if got := sd.String(); got != test.text {
+			t.Errorf("TestParticipantString => input: %q, got %q", test.text, got)
+		}
+	}
+}
+
+func nodeNames(nodes []*Node) (names []string) {
+	for _, node := range nodes {
+		names = append(names, node.Name)
+	}
+	return
+}
+
 func messageTypes(messages ...Message) (types []reflect.Type) {
 	for _, msg := range messages {
 		types = append(types, reflect.TypeOf(msg))
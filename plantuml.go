@@ -0,0 +1,215 @@
+package sequencediagram
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PlantUML returns the sequence diagram in PlantUML syntax. Participants are
+// declared up front so PlantUML keeps the order of the nodes.
+func (sd *Diagram) PlantUML() string {
+	ids := participantIDs(sd.GetOrderedNodes())
+	lines := []string{"@startuml"}
+	if title, ok := sd.lastTitle(); ok {
+		lines = append(lines, "title "+title)
+	}
+	declare := func(node *Node) string {
+		if ids[node] == node.Name {
+			return "participant " + node.Name
+		}
+		return `participant "` + node.Name + `" as ` + ids[node]
+	}
+	for _, nodes := range sd.groupedNodes() {
+		if nodes.group == nil {
+			lines = append(lines, declare(nodes.nodes[0]))
+			continue
+		}
+		lines = append(lines, `box "`+nodes.group.Name+`"`)
+		for _, node := range nodes.nodes {
+			lines = append(lines, declare(node))
+		}
+		lines = append(lines, "end box")
+	}
+	for _, message := range sd.messages {
+		switch message := message.(type) {
+		case SelfMessage:
+			lines = append(lines, ids[message.Self]+" "+message.arrow()+" "+ids[message.Self]+plantUMLLabel(message.Msg))
+		case ForwardMessage:
+			lines = append(lines, ids[message.From]+" "+message.arrow()+" "+ids[message.To]+plantUMLLabel(message.Msg))
+		case BackwardMessage:
+			lines = append(lines, ids[message.From]+" "+message.arrow()+" "+ids[message.To]+plantUMLLabel(message.Msg))
+		case Note:
+			side := "left"
+			if message.Side == Right {
+				side = "right"
+			}
+			lines = append(lines, "note "+side+" of "+ids[message.Node]+" : "+message.Msg)
+		case Divider, Loop, EndLoop:
+			lines = append(lines, message.String())
+		}
+	}
+	lines = append(lines, "@enduml")
+	return strings.Join(lines, "\n")
+}
+
+// plantUMLLabel is the label of a message, messages without one have no colon
+func plantUMLLabel(msg string) string {
+	if msg == "" {
+		return ""
+	}
+	return " : " + msg
+}
+
+const plantUMLRef = `(?:"([^"]+)"|([A-Za-z0-9_.]+))`
+
+var (
+	plantUMLStartPattern       = regexp.MustCompile("^@startuml\\b")
+	plantUMLEndPattern         = regexp.MustCompile("^@enduml$")
+	plantUMLTitlePattern       = regexp.MustCompile("^title\\s+(.+)$")
+	plantUMLParticipantPattern = regexp.MustCompile("^(?:participant|actor|boundary|control|entity|database|collections|queue)\\s+(.+?)(?:\\s+order\\s+(-?[0-9]+))?(?:\\s+#\\S+)?$")
+	plantUMLAliasPattern       = regexp.MustCompile("^" + plantUMLRef + "(?:\\s+as\\s+" + plantUMLRef + ")?$")
+	plantUMLBoxPattern         = regexp.MustCompile(`^box(?:\s+"([^"]*)"|\s+([^#\s]+))?(?:\s+#\S+)?$`)
+	plantUMLEndBoxPattern      = regexp.MustCompile("^end\\s+box$")
+	plantUMLMessagePattern     = regexp.MustCompile("^" + plantUMLRef + "\\s*(-->>|->>|-->|->|<<--|<<-|<--|<-)\\s*" + plantUMLRef + "\\s*(?::(.*))?$")
+	plantUMLNotePattern        = regexp.MustCompile("^note\\s+(left of|right of|over)\\s+" + plantUMLRef + "\\s*:(.*)$")
+	plantUMLDividerPattern     = regexp.MustCompile("^==\\s*(.+?)\\s*==$")
+	plantUMLLoopPattern        = regexp.MustCompile("^loop\\s+(.+)$")
+	plantUMLStatementPattern   = regexp.MustCompile("^(activate|deactivate|destroy|create|autonumber|alt|else|opt|loop|par|break|critical|group|end|note|ref|hnote|rnote|skinparam|hide|show|return|newpage|autoactivate|header|footer|legend|\\.\\.\\.|\\|\\|\\|)")
+)
+
+// ParseFromPlantUML parses a PlantUML sequence diagram into a sequence diagram.
+// All participant types are read as participants and notes over a participant
+// are placed right of it. Every line outside of the supported subset is
+// reported in the error.
+func ParseFromPlantUML(s string) (*Diagram, error) {
+	sd := &Diagram{}
+	// participant aliases to node names from "participant "Name" as X"
+	aliases := make(map[string]string)
+	node := func(quoted, id string) *Node {
+		if quoted != "" {
+			return sd.getOrCreateNode(quoted)
+		}
+		if name, ok := aliases[id]; ok {
+			return sd.getOrCreateNode(name)
+		}
+		return sd.getOrCreateNode(id)
+	}
+	var errs []string
+	var group *Group
+	var groupLine int
+	// line numbers of the loops that are not ended yet
+	var loopLines []int
+	for i, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "'") || plantUMLStartPattern.MatchString(line) {
+			continue
+		}
+		if plantUMLEndPattern.MatchString(line) {
+			break
+		}
+		switch {
+		case group != nil && plantUMLEndBoxPattern.MatchString(line):
+			sd.messages = append(sd.messages, EndBox{noMessage{}})
+			group = nil
+		case plantUMLParticipantPattern.MatchString(line):
+			match := plantUMLParticipantPattern.FindStringSubmatch(line)
+			alias := plantUMLAliasPattern.FindStringSubmatch(match[1])
+			if alias == nil {
+				errs = append(errs, fmt.Sprintf("Line %d: Syntax error.", i+1))
+				continue
+			}
+			// the quoted (or else the first) name is displayed, the other one is the alias
+			name, id := alias[1]+alias[2], alias[3]+alias[4]
+			if alias[3] != "" {
+				name, id = id, name
+			}
+			if id != "" {
+				aliases[id] = name
+			}
+			if _, ok := sd.nodes[name]; ok && group != nil {
+				errs = append(errs, fmt.Sprintf("Line %d: Participant %s already declared outside of box.", i+1, name))
+				continue
+			}
+			n := sd.getOrCreateNode(name)
+			if group != nil {
+				group.Nodes = append(group.Nodes, n)
+			}
+			participant := Participant{Self: n}
+			if match[2] != "" {
+				participant.Rank, _ = strconv.Atoi(match[2])
+				participant.Ranked = true
+			}
+			sd.messages = append(sd.messages, participant)
+		case group != nil:
+			errs = append(errs, fmt.Sprintf("Line %d: Only participants can be declared in a box.", i+1))
+		case plantUMLBoxPattern.MatchString(line):
+			match := plantUMLBoxPattern.FindStringSubmatch(line)
+			group = &Group{Name: match[1] + match[2]}
+			groupLine = i + 1
+			sd.groups = append(sd.groups, group)
+			sd.messages = append(sd.messages, Box{group, noMessage{}})
+		case plantUMLTitlePattern.MatchString(line):
+			title := plantUMLTitlePattern.FindStringSubmatch(line)[1]
+			sd.messages = append(sd.messages, Title{simpleMessage{title}})
+		case plantUMLDividerPattern.MatchString(line):
+			divider := plantUMLDividerPattern.FindStringSubmatch(line)[1]
+			sd.messages = append(sd.messages, Divider{simpleMessage{divider}})
+		case plantUMLMessagePattern.MatchString(line):
+			match := plantUMLMessagePattern.FindStringSubmatch(line)
+			from, arrow, to := node(match[1], match[2]), match[3], node(match[4], match[5])
+			// reverse arrows point from right to left
+			if strings.HasPrefix(arrow, "<") {
+				from, to = to, from
+			}
+			udm := uniDirectionalMessage{
+				AltArrowBody: strings.Contains(arrow, "--"),
+				AltArrowEnd:  strings.Contains(arrow, ">>") || strings.Contains(arrow, "<<"),
+			}
+			msg := strings.TrimSpace(match[6])
+			sd.messages = append(sd.messages, classifyMessage(from, to, simpleMessage{msg}, udm))
+		case plantUMLNotePattern.MatchString(line):
+			match := plantUMLNotePattern.FindStringSubmatch(line)
+			side := Right
+			if match[1] == "left of" {
+				side = Left
+			}
+			msg := strings.TrimSpace(match[4])
+			sd.messages = append(sd.messages, Note{node(match[2], match[3]), side, simpleMessage{msg}})
+		case plantUMLLoopPattern.MatchString(line):
+			loop := plantUMLLoopPattern.FindStringSubmatch(line)[1]
+			loopLines = append(loopLines, i+1)
+			sd.messages = append(sd.messages, Loop{simpleMessage{loop}})
+		case line == "end" && len(loopLines) > 0:
+			loopLines = loopLines[:len(loopLines)-1]
+			sd.messages = append(sd.messages, EndLoop{noMessage{}})
+		case plantUMLStatementPattern.MatchString(line):
+			errs = append(errs, fmt.Sprintf("Line %d: Unsupported statement %s.", i+1, plantUMLStatementPattern.FindString(line)))
+		default:
+			errs = append(errs, fmt.Sprintf("Line %d: Syntax error.", i+1))
+		}
+	}
+	if group != nil {
+		errs = append(errs, fmt.Sprintf("Line %d: Box is never ended.", groupLine))
+	}
+	for _, loopLine := range loopLines {
+		errs = append(errs, fmt.Sprintf("Line %d: Loop is never ended.", loopLine))
+	}
+	if len(errs) > 0 {
+		// errors of unended blocks are found last but reported at their line
+		sort.SliceStable(errs, func(i, j int) bool { return errorLine(errs[i]) < errorLine(errs[j]) })
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+	sd.applyRanks()
+	return sd, nil
+}
+
+// errorLine is the line number of a "Line N: ..." error
+func errorLine(err string) int {
+	var line int
+	fmt.Sscanf(err, "Line %d:", &line)
+	return line
+}
@@ -0,0 +1,6 @@
+module github.com/Laugusti/sequencediagram/pngdiagram
+
+require (
+	github.com/Laugusti/sequencediagram v0.0.3
+	github.com/Laugusti/sequencediagram/textdiagram v0.0.0-20180910194023-88ec69161d3c
+)
@@ -0,0 +1,191 @@
+// package pngdiagram provides functionality to create an image of a sequence diagram
+package pngdiagram
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"io/ioutil"
+
+	"github.com/Laugusti/sequencediagram"
+	"github.com/Laugusti/sequencediagram/internal/cells"
+	"github.com/Laugusti/sequencediagram/textdiagram"
+)
+
+const (
+	// each character of the text diagram is drawn in a cell, the font leaves
+	// a column and the top and bottom row of the cell empty
+	cell_width  = 6
+	cell_height = 9
+
+	// box drawing lines meet in the center of the cell
+	center_x = 2
+	center_y = 4
+)
+
+// Options configures the image created by Encode
+type Options struct {
+	// Scale is the size of a pixel of the font, values below 1 use 1
+	Scale int
+	// Transparent leaves the background transparent instead of white
+	Transparent bool
+}
+
+// Encode creates an image of the sequence diagram. The image uses the same
+// layout as the text diagram created by textdiagram.Encode, with the box
+// drawing characters and the arrows drawn as lines.
+func Encode(sd *sequencediagram.Diagram, opts Options) (image.Image, error) {
+	b, err := ioutil.ReadAll(textdiagram.Encode(sd))
+	if err != nil {
+		return nil, err
+	}
+	rows, width := cells.Grid(string(b))
+
+	c := &canvas{scale: opts.Scale}
+	if c.scale < 1 {
+		c.scale = 1
+	}
+	c.img = image.NewNRGBA(image.Rect(0, 0, width*cell_width*c.scale, len(rows)*cell_height*c.scale))
+	if !opts.Transparent {
+		draw.Draw(c.img, c.img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	}
+	for row, line := range rows {
+		for col, shape := range cells.Row(line) {
+			if shape == nil {
+				c.drawGlyph(col*cell_width, row*cell_height, line[col])
+			} else {
+				c.drawShape(col*cell_width, row*cell_height, shape)
+			}
+		}
+	}
+	return c.img, nil
+}
+
+// EncodePNG writes an image of the sequence diagram to w in PNG format
+func EncodePNG(w io.Writer, sd *sequencediagram.Diagram, opts Options) error {
+	img, err := Encode(sd, opts)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+// canvas draws in font pixels, which are scale by scale image pixels
+type canvas struct {
+	img   *image.NRGBA
+	scale int
+}
+
+func (c *canvas) set(x, y int) {
+	for i := 0; i < c.scale; i++ {
+		for j := 0; j < c.scale; j++ {
+			c.img.Set(x*c.scale+i, y*c.scale+j, color.Black)
+		}
+	}
+}
+
+// hline draws a horizontal line in the cell from x1 to x2 inclusive, skipping
+// every gap'th pixel if gap is positive
+func (c *canvas) hline(x, y, x1, x2, gap int) {
+	for i := x1; i <= x2; i++ {
+		if gap > 0 && (x+i)%gap == gap-1 {
+			continue
+		}
+		c.set(x+i, y+center_y)
+	}
+}
+
+// vline draws a vertical line in the cell from y1 to y2 inclusive, skipping
+// every gap'th pixel if gap is positive
+func (c *canvas) vline(x, y, y1, y2, gap int) {
+	for j := y1; j <= y2; j++ {
+		if gap > 0 && j%gap == gap-1 {
+			continue
+		}
+		c.set(x+center_x, y+j)
+	}
+}
+
+// drawShape draws the lines of the shape in the cell with top left corner x, y
+func (c *canvas) drawShape(x, y int, shape *cells.Shape) {
+	const right = cell_width - 1
+	for _, segment := range shape.Segments {
+		horizontal := segment.From == cells.Left || segment.From == cells.Right || segment.To == cells.Left || segment.To == cells.Right
+		from, to := along(segment.From, horizontal), along(segment.To, horizontal)
+		if from > to {
+			from, to = to, from
+		}
+		gap := 0
+		if segment.Style == cells.Dashed {
+			gap = 3
+		}
+		offsets := []int{0}
+		if segment.Style == cells.Double {
+			offsets = []int{-1, 1}
+		}
+		for _, offset := range offsets {
+			if horizontal {
+				c.hline(x, y+offset, from, to, gap)
+			} else {
+				c.vline(x+offset, y, from, to, gap)
+			}
+		}
+	}
+	if shape.Fold {
+		c.set(x+center_x-2, y+center_y+1)
+		c.set(x+center_x-2, y+center_y+2)
+		c.set(x+center_x-1, y+center_y+2)
+	}
+	switch shape.Head {
+	case cells.FilledRight, cells.FilledLeft:
+		// filled triangle with the tip at the edge of the cell
+		for i := 0; i < 4; i++ {
+			px := x + i
+			if shape.Head == cells.FilledLeft {
+				px = x + right - i
+			}
+			for j := -(3 - i); j <= 3-i; j++ {
+				c.set(px, y+center_y+j)
+			}
+		}
+	case cells.OpenRight, cells.OpenLeft:
+		c.hline(x, y, 0, right, 0)
+		for i := 0; i < 4; i++ {
+			px := x + right - i
+			if shape.Head == cells.OpenLeft {
+				px = x + i
+			}
+			c.set(px, y+center_y-i)
+			c.set(px, y+center_y+i)
+		}
+	}
+}
+
+// along is the position of the point along a horizontal or vertical line
+func along(p cells.Point, horizontal bool) int {
+	switch {
+	case p == cells.Right:
+		return cell_width - 1
+	case p == cells.Bottom:
+		return cell_height - 1
+	case p == cells.Center && horizontal:
+		return center_x
+	case p == cells.Center:
+		return center_y
+	}
+	return 0
+}
+
+// drawGlyph draws r with the font in the cell with top left corner x, y
+func (c *canvas) drawGlyph(x, y int, r rune) {
+	g := glyph(r)
+	for i, column := range g {
+		for j := 0; j < 7; j++ {
+			if column&(1<<uint(j)) != 0 {
+				c.set(x+i, y+1+j)
+			}
+		}
+	}
+}
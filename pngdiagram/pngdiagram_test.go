@@ -0,0 +1,75 @@
+package pngdiagram
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/Laugusti/sequencediagram"
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		text        string
+		scale       int
+		transparent bool
+		width       int
+		height      int
+	}{
+		// text diagram is 17 runes wide and 9 lines high
+		{"a->b:msg", 1, false, 17 * cell_width, 9 * cell_height},
+		{"a->b:msg", 0, true, 17 * cell_width, 9 * cell_height},
+		{"a->b:msg", 3, false, 3 * 17 * cell_width, 3 * 9 * cell_height},
+	}
+	for _, test := range tests {
+		img, err := Encode(parse(t, test.text), Options{Scale: test.scale, Transparent: test.transparent})
+		if err != nil {
+			t.Errorf("TestEncode => got error: %v", err)
+			continue
+		}
+		bounds := img.Bounds()
+		if bounds.Dx() != test.width || bounds.Dy() != test.height {
+			t.Errorf("TestEncode => input: %q scale: %d, got size %dx%d, want %dx%d", test.text, test.scale, bounds.Dx(), bounds.Dy(), test.width, test.height)
+		}
+		// top left corner of the first header box
+		scale := test.scale
+		if scale < 1 {
+			scale = 1
+		}
+		x, y := (center_x+1)*scale, center_y*scale
+		if _, _, _, a := img.At(x, y).RGBA(); a == 0 {
+			t.Errorf("TestEncode => input: %q scale: %d, expected box line at (%d, %d)", test.text, scale, x, y)
+		}
+		// background
+		want := color.NRGBAModel.Convert(color.White)
+		if test.transparent {
+			want = color.NRGBA{}
+		}
+		if got := img.At(0, 0); got != want {
+			t.Errorf("TestEncode => input: %q transparent: %t, got background %v, want %v", test.text, test.transparent, got, want)
+		}
+	}
+}
+
+func TestEncodePNG(t *testing.T) {
+	var b bytes.Buffer
+	if err := EncodePNG(&b, parse(t, "title Title\na->b:msg\nb-->>a:resp\nnote right of b:note"), Options{}); err != nil {
+		t.Fatalf("TestEncodePNG => got error: %v", err)
+	}
+	img, err := png.Decode(&b)
+	if err != nil {
+		t.Fatalf("TestEncodePNG => error decoding png: %v", err)
+	}
+	if img.Bounds().Empty() {
+		t.Errorf("TestEncodePNG => got empty image")
+	}
+}
+
+func parse(t *testing.T, s string) *sequencediagram.Diagram {
+	sd, err := sequencediagram.ParseFromText(s)
+	if err != nil {
+		t.Fatalf("error parsing sequence diagram: %v", err)
+	}
+	return sd
+}
@@ -0,0 +1,83 @@
+package sequencediagram
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+)
+
+// Recorder builds a sequence diagram from calls made while a program runs. It
+// is safe for concurrent use and the zero value is an empty recorder.
+type Recorder struct {
+	mu sync.Mutex
+	sd Diagram
+	// number of loop blocks that are not closed yet
+	loops int
+	id    string
+}
+
+// ID returns a random id of the recorder, the same for every call. It tells
+// the recorder apart from others, also in other processes.
+func (r *Recorder) ID() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.id == "" {
+		b := make([]byte, 8)
+		rand.Read(b)
+		r.id = hex.EncodeToString(b)
+	}
+	return r.id
+}
+
+// Call records a request from one node to another
+func (r *Recorder) Call(from, to, msg string) {
+	r.add(from, to, msg, false)
+}
+
+// Return records a response from one node to another, drawn with a dashed arrow
+func (r *Recorder) Return(from, to, msg string) {
+	r.add(from, to, msg, true)
+}
+
+func (r *Recorder) add(from, to, msg string, altArrowBody bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sd.messages = append(r.sd.messages, classifyMessage(r.sd.getOrCreateNode(from), r.sd.getOrCreateNode(to),
+		simpleMessage{msg}, uniDirectionalMessage{AltArrowBody: altArrowBody}))
+}
+
+// Note records a note on the given side of a node
+func (r *Recorder) Note(node string, side Side, msg string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sd.messages = append(r.sd.messages, Note{r.sd.getOrCreateNode(node), side, simpleMessage{msg}})
+}
+
+// Loop starts a block of repeated messages labelled msg, the block is closed by
+// EndLoop
+func (r *Recorder) Loop(msg string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sd.messages = append(r.sd.messages, Loop{simpleMessage{msg}})
+	r.loops++
+}
+
+// EndLoop closes the last loop block started by Loop, it is ignored when no
+// loop block is open
+func (r *Recorder) EndLoop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.loops == 0 {
+		return
+	}
+	r.sd.messages = append(r.sd.messages, EndLoop{noMessage{}})
+	r.loops--
+}
+
+// Diagram returns a copy of the sequence diagram recorded so far. Later calls
+// to the recorder do not change the returned diagram.
+func (r *Recorder) Diagram() *Diagram {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sd.clone()
+}
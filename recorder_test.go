@@ -0,0 +1,101 @@
+package sequencediagram
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestRecorderConcurrent(t *testing.T) {
+	var r Recorder
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				r.Call(fmt.Sprintf("client%d", i), "server", "req")
+				r.Return("server", fmt.Sprintf("client%d", i), "resp")
+			}
+		}(i)
+	}
+	wg.Wait()
+	sd := r.Diagram()
+	if got := len(sd.Messages()); got != 2000 {
+		t.Errorf("TestRecorderConcurrent => expected 2000 messages, got %d", got)
+	}
+	if got := len(sd.GetOrderedNodes()); got != 11 {
+		t.Errorf("TestRecorderConcurrent => expected 11 nodes, got %d", got)
+	}
+	for i, node := range sd.GetOrderedNodes() {
+		if node.Order != i {
+			t.Errorf("TestRecorderConcurrent => expected node %s to have order %d, got %d", node.Name, i, node.Order)
+		}
+	}
+}
+
+func TestRecorderDiagramIsSnapshot(t *testing.T) {
+	var r Recorder
+	r.Call("a", "b", "first")
+	sd := r.Diagram()
+	if err := sd.Reorder([]string{"b", "a"}); err != nil {
+		t.Fatalf("TestRecorderDiagramIsSnapshot => got reorder error: %v", err)
+	}
+	r.Call("a", "c", "second")
+
+	if got := nodeNames(sd.GetOrderedNodes()); !reflect.DeepEqual(got, []string{"b", "a"}) {
+		t.Errorf("TestRecorderDiagramIsSnapshot => expected snapshot nodes [b a], got %v", got)
+	}
+	if got := sd.String(); got != "a->b:first" {
+		t.Errorf("TestRecorderDiagramIsSnapshot => expected snapshot %q, got %q", "a->b:first", got)
+	}
+	want := []reflect.Type{reflect.TypeOf(ForwardMessage{}), reflect.TypeOf(ForwardMessage{})}
+	var got []reflect.Type
+	for _, message := range r.Diagram().Messages() {
+		got = append(got, reflect.TypeOf(message))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TestRecorderDiagramIsSnapshot => expected recorder messages %v, got %v", want, got)
+	}
+}
+
+func TestRecorderUnmatchedEndLoop(t *testing.T) {
+	tests := []struct {
+		record func(r *Recorder)
+		want   string
+	}{
+		{func(r *Recorder) { r.EndLoop() }, ""},
+		{func(r *Recorder) {
+			r.EndLoop()
+			r.Loop("l")
+			r.Call("a", "b", "x")
+			r.EndLoop()
+			r.EndLoop()
+		}, "loop l\na->b:x\nend"},
+		// loops without participants
+		{func(r *Recorder) {
+			r.Loop("l")
+			r.EndLoop()
+			r.EndLoop()
+		}, "loop l\nend"},
+	}
+	for i, test := range tests {
+		var r Recorder
+		test.record(&r)
+		if got := r.Diagram().String(); got != test.want {
+			t.Errorf("TestRecorderUnmatchedEndLoop => case %d: got %q, want %q", i, got, test.want)
+		}
+	}
+}
+
+func TestRecorderID(t *testing.T) {
+	var a, b Recorder
+	id := a.ID()
+	if len(id) != 16 || a.ID() != id {
+		t.Errorf("TestRecorderID => got ids %q and %q, want the same 16 hex digits", id, a.ID())
+	}
+	if b.ID() == id {
+		t.Errorf("TestRecorderID => two recorders got the same id %q", id)
+	}
+}
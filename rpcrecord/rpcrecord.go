@@ -0,0 +1,153 @@
+// package rpcrecord records gRPC style calls as a sequence diagram. The
+// interceptor types have the shape of the gRPC server interceptors without
+// depending on gRPC, a gRPC server adapts them with a one line closure.
+package rpcrecord
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Laugusti/sequencediagram"
+)
+
+// DefaultCaller is the participant name used for calls without a caller in the context
+const DefaultCaller = "Client"
+
+// StatusOK is the return message of calls that succeed
+const StatusOK = "OK"
+
+// UnaryServerInfo describes the unary call being intercepted
+type UnaryServerInfo struct {
+	// FullMethod is the full method name, "/package.Service/Method"
+	FullMethod string
+}
+
+// UnaryHandler handles a unary call
+type UnaryHandler func(ctx context.Context, req interface{}) (interface{}, error)
+
+// UnaryInterceptor is called for each unary call instead of the handler
+type UnaryInterceptor func(ctx context.Context, req interface{}, info *UnaryServerInfo, handler UnaryHandler) (interface{}, error)
+
+// ServerStream is the server side of a streaming call
+type ServerStream interface {
+	Context() context.Context
+	SendMsg(m interface{}) error
+	RecvMsg(m interface{}) error
+}
+
+// StreamServerInfo describes the streaming call being intercepted
+type StreamServerInfo struct {
+	// FullMethod is the full method name, "/package.Service/Method"
+	FullMethod     string
+	IsClientStream bool
+	IsServerStream bool
+}
+
+// StreamHandler handles a streaming call
+type StreamHandler func(srv interface{}, stream ServerStream) error
+
+// StreamInterceptor is called for each streaming call instead of the handler
+type StreamInterceptor func(srv interface{}, ss ServerStream, info *StreamServerInfo, handler StreamHandler) error
+
+type contextKey struct{}
+
+// WithCaller returns a context for calls made by the named participant
+func WithCaller(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, contextKey{}, name)
+}
+
+func callerFromContext(ctx context.Context) string {
+	if name, ok := ctx.Value(contextKey{}).(string); ok && name != "" {
+		return name
+	}
+	return DefaultCaller
+}
+
+// Unary returns an interceptor that records each unary call to the named
+// service as a call with the method name, followed by a dashed return with
+// StatusOK or the error text. The handler's context carries name as the
+// caller, so the calls it makes are recorded from name.
+func Unary(rec *sequencediagram.Recorder, name string) UnaryInterceptor {
+	return func(ctx context.Context, req interface{}, info *UnaryServerInfo, handler UnaryHandler) (interface{}, error) {
+		caller := callerFromContext(ctx)
+		rec.Call(caller, name, methodName(info.FullMethod))
+		resp, err := handler(WithCaller(ctx, name), req)
+		rec.Return(name, caller, status(err))
+		return resp, err
+	}
+}
+
+// Stream returns an interceptor that records each streaming call to the named
+// service like Unary, with the messages received and sent on the stream
+// inside a loop block. The messages are labelled with their type.
+func Stream(rec *sequencediagram.Recorder, name string) StreamInterceptor {
+	return func(srv interface{}, ss ServerStream, info *StreamServerInfo, handler StreamHandler) error {
+		caller := callerFromContext(ss.Context())
+		rec.Call(caller, name, methodName(info.FullMethod))
+		stream := &recordedStream{ServerStream: ss, rec: rec, caller: caller, name: name}
+		err := handler(srv, stream)
+		if stream.looping {
+			rec.EndLoop()
+		}
+		rec.Return(name, caller, status(err))
+		return err
+	}
+}
+
+// recordedStream records the messages of a stream, the loop block is started
+// with the first message. Messages can be sent and received concurrently.
+type recordedStream struct {
+	ServerStream
+	rec          *sequencediagram.Recorder
+	caller, name string
+	once         sync.Once
+	looping      bool
+}
+
+func (s *recordedStream) Context() context.Context {
+	return WithCaller(s.ServerStream.Context(), s.name)
+}
+
+func (s *recordedStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.loop()
+		s.rec.Call(s.name, s.caller, messageType(m))
+	}
+	return err
+}
+
+func (s *recordedStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.loop()
+		s.rec.Call(s.caller, s.name, messageType(m))
+	}
+	return err
+}
+
+func (s *recordedStream) loop() {
+	s.once.Do(func() {
+		s.looping = true
+		s.rec.Loop("stream")
+	})
+}
+
+// methodName returns the method of "/package.Service/Method"
+func methodName(fullMethod string) string {
+	return fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+}
+
+// messageType returns the type name of a message without the pointer
+func messageType(m interface{}) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", m), "*")
+}
+
+func status(err error) string {
+	if err == nil {
+		return StatusOK
+	}
+	return err.Error()
+}
@@ -0,0 +1,89 @@
+package rpcrecord
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/Laugusti/sequencediagram"
+)
+
+type GetUserRequest struct{}
+type User struct{}
+
+// fakeStream receives n messages and records nothing about sent messages
+type fakeStream struct {
+	ctx context.Context
+	n   int
+}
+
+func (s *fakeStream) Context() context.Context    { return s.ctx }
+func (s *fakeStream) SendMsg(m interface{}) error { return nil }
+func (s *fakeStream) RecvMsg(m interface{}) error {
+	if s.n == 0 {
+		return io.EOF
+	}
+	s.n--
+	return nil
+}
+
+func TestUnary(t *testing.T) {
+	var rec sequencediagram.Recorder
+	users := Unary(&rec, "Users")
+	frontend := Unary(&rec, "Frontend")
+
+	getUser := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return users(ctx, req, &UnaryServerInfo{"/users.Users/GetUser"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, errors.New("rpc error: code = NotFound desc = no user 1")
+		})
+	}
+	frontend(WithCaller(context.Background(), "Test"), &GetUserRequest{}, &UnaryServerInfo{"/web.Frontend/Profile"}, getUser)
+
+	want := `Test->Frontend:Profile
+Frontend->Users:GetUser
+Users-->Frontend:rpc error: code = NotFound desc = no user 1
+Frontend-->Test:rpc error: code = NotFound desc = no user 1`
+	if got := rec.Diagram().String(); got != want {
+		t.Errorf("TestUnary => got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestStream(t *testing.T) {
+	tests := []struct {
+		received int
+		sent     int
+		err      error
+		want     string
+	}{
+		{0, 0, nil, "Client->Users:ListUsers\nUsers-->Client:OK"},
+		{1, 2, nil, `Client->Users:ListUsers
+loop stream
+Client->Users:rpcrecord.GetUserRequest
+Users->Client:rpcrecord.User
+Users->Client:rpcrecord.User
+end
+Users-->Client:OK`},
+		{0, 1, errors.New("rpc error: code = Unavailable desc = closing"), `Client->Users:ListUsers
+loop stream
+Users->Client:rpcrecord.User
+end
+Users-->Client:rpc error: code = Unavailable desc = closing`},
+	}
+	for _, test := range tests {
+		var rec sequencediagram.Recorder
+		stream := Stream(&rec, "Users")
+		ss := &fakeStream{context.Background(), test.received}
+		stream(nil, ss, &StreamServerInfo{FullMethod: "/users.Users/ListUsers", IsServerStream: true}, func(srv interface{}, ss ServerStream) error {
+			for ss.RecvMsg(&GetUserRequest{}) == nil {
+			}
+			for i := 0; i < test.sent; i++ {
+				ss.SendMsg(&User{})
+			}
+			return test.err
+		})
+		if got := rec.Diagram().String(); got != test.want {
+			t.Errorf("TestStream => got:\n%s\nwant:\n%s", got, test.want)
+		}
+	}
+}
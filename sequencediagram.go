@@ -2,18 +2,33 @@
 package sequencediagram
 
 import (
+	"fmt"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 )
 
+var (
+	identifierPattern    = regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*$")
+	nonIdentifierPattern = regexp.MustCompile("[^A-Za-z0-9_]+")
+)
+
 type Node struct {
 	Name  string
 	Order int
 }
 
+// Group is a named set of participants that are drawn inside a common box
+type Group struct {
+	Name  string
+	Nodes []*Node
+}
+
 type Diagram struct {
 	messages []Message
 	nodes    map[string]*Node
+	groups   []*Group
 }
 
 func (sd *Diagram) getOrCreateNode(name string) *Node {
@@ -30,7 +45,15 @@ func (sd *Diagram) Messages() []Message {
 	return sd.messages
 }
 
-// GetOrderedNodes returns an ordered Node slice for the sequence diagram
+// Groups returns the participant groups of the sequence diagram in the order
+// they were declared
+func (sd *Diagram) Groups() []*Group {
+	return sd.groups
+}
+
+// GetOrderedNodes returns an ordered Node slice for the sequence diagram. The
+// members of a Group are always contiguous since participants can only join a
+// group when they are first declared.
 func (sd *Diagram) GetOrderedNodes() []*Node {
 	var nodes []*Node
 	for _, node := range sd.nodes {
@@ -40,6 +63,201 @@ func (sd *Diagram) GetOrderedNodes() []*Node {
 	return nodes
 }
 
+// Reorder changes the order of the nodes. The named nodes are placed first in
+// the given order, followed by the remaining nodes in their current order. The
+// members of a group are kept together at the position of the group's first
+// member. Messages are reclassified as forward or backward messages to match
+// the new order.
+func (sd *Diagram) Reorder(names []string) error {
+	listed := make(map[*Node]bool)
+	var nodes []*Node
+	for _, name := range names {
+		node, ok := sd.nodes[name]
+		if !ok {
+			return fmt.Errorf("Participant %s does not exist.", name)
+		}
+		if listed[node] {
+			return fmt.Errorf("Participant %s is listed more than once.", name)
+		}
+		listed[node] = true
+		nodes = append(nodes, node)
+	}
+	for _, node := range sd.GetOrderedNodes() {
+		if !listed[node] {
+			nodes = append(nodes, node)
+		}
+	}
+	sd.setOrder(nodes)
+	return nil
+}
+
+// setOrder sets the Order of each node to its index in nodes, after moving the
+// members of each group next to the group's first member
+func (sd *Diagram) setOrder(nodes []*Node) {
+	index := make(map[*Node]int)
+	for i, node := range nodes {
+		index[node] = i
+	}
+	groupOf := make(map[*Node]*Group)
+	for _, group := range sd.groups {
+		sort.SliceStable(group.Nodes, func(i, j int) bool { return index[group.Nodes[i]] < index[group.Nodes[j]] })
+		for _, node := range group.Nodes {
+			groupOf[node] = group
+		}
+	}
+
+	var ordered []*Node
+	placed := make(map[*Group]bool)
+	for _, node := range nodes {
+		group, ok := groupOf[node]
+		if !ok {
+			ordered = append(ordered, node)
+		} else if !placed[group] {
+			placed[group] = true
+			ordered = append(ordered, group.Nodes...)
+		}
+	}
+	for i, node := range ordered {
+		node.Order = i
+	}
+
+	// the direction of a message depends on the order of its nodes
+	for i, message := range sd.messages {
+		switch message := message.(type) {
+		case ForwardMessage:
+			sd.messages[i] = classifyMessage(message.From, message.To, message.simpleMessage, message.uniDirectionalMessage)
+		case BackwardMessage:
+			sd.messages[i] = classifyMessage(message.From, message.To, message.simpleMessage, message.uniDirectionalMessage)
+		}
+	}
+}
+
+// clone returns a deep copy of the sequence diagram
+func (sd *Diagram) clone() *Diagram {
+	c := &Diagram{nodes: make(map[string]*Node)}
+	nodes := make(map[*Node]*Node)
+	for name, node := range sd.nodes {
+		n := *node
+		c.nodes[name] = &n
+		nodes[node] = &n
+	}
+	groups := make(map[*Group]*Group)
+	for _, group := range sd.groups {
+		g := &Group{Name: group.Name}
+		for _, node := range group.Nodes {
+			g.Nodes = append(g.Nodes, nodes[node])
+		}
+		c.groups = append(c.groups, g)
+		groups[group] = g
+	}
+	for _, message := range sd.messages {
+		switch m := message.(type) {
+		case Participant:
+			m.Self = nodes[m.Self]
+			message = m
+		case SelfMessage:
+			m.Self = nodes[m.Self]
+			message = m
+		case ForwardMessage:
+			m.From, m.To = nodes[m.From], nodes[m.To]
+			message = m
+		case BackwardMessage:
+			m.From, m.To = nodes[m.From], nodes[m.To]
+			message = m
+		case Note:
+			m.Node = nodes[m.Node]
+			message = m
+		case Box:
+			m.Group = groups[m.Group]
+			message = m
+		}
+		c.messages = append(c.messages, message)
+	}
+	return c
+}
+
+// lastTitle returns the title of the sequence diagram, the last title wins if
+// there is more than one
+func (sd *Diagram) lastTitle() (string, bool) {
+	var title string
+	var ok bool
+	for _, message := range sd.messages {
+		if t, isTitle := message.(Title); isTitle {
+			title, ok = t.Msg, true
+		}
+	}
+	return title, ok
+}
+
+// nodeRun is either a single node that is not in a group or all nodes of a group
+type nodeRun struct {
+	group *Group
+	nodes []*Node
+}
+
+// groupedNodes returns the ordered nodes, with the members of each group together
+func (sd *Diagram) groupedNodes() []nodeRun {
+	groupOf := make(map[*Node]*Group)
+	for _, group := range sd.groups {
+		for _, node := range group.Nodes {
+			groupOf[node] = group
+		}
+	}
+	var runs []nodeRun
+	for _, node := range sd.GetOrderedNodes() {
+		group := groupOf[node]
+		if group != nil && len(runs) > 0 && runs[len(runs)-1].group == group {
+			runs[len(runs)-1].nodes = append(runs[len(runs)-1].nodes, node)
+			continue
+		}
+		runs = append(runs, nodeRun{group, []*Node{node}})
+	}
+	return runs
+}
+
+// participantIDs returns an identifier for each node that other formats accept
+// without quoting. Names that are already valid identifiers are kept as is.
+func participantIDs(nodes []*Node) map[*Node]string {
+	ids := make(map[*Node]string)
+	used := make(map[string]bool)
+	// valid names go first so generated ids never take them
+	for _, node := range nodes {
+		if isIdentifier(node.Name) {
+			ids[node] = node.Name
+			used[strings.ToLower(node.Name)] = true
+		}
+	}
+	for _, node := range nodes {
+		if _, ok := ids[node]; ok {
+			continue
+		}
+		base := strings.Trim(nonIdentifierPattern.ReplaceAllString(node.Name, "_"), "_")
+		if !isIdentifier(base) {
+			base = "P_" + base
+		}
+		id := base
+		for i := 2; used[strings.ToLower(id)]; i++ {
+			id = base + "_" + strconv.Itoa(i)
+		}
+		ids[node] = id
+		used[strings.ToLower(id)] = true
+	}
+	return ids
+}
+
+// keywords of the supported formats that cannot be used as identifiers
+var keywords = map[string]bool{
+	"activate": true, "actor": true, "alt": true, "and": true, "as": true, "autonumber": true,
+	"box": true, "break": true, "critical": true, "database": true, "deactivate": true,
+	"else": true, "end": true, "left": true, "loop": true, "note": true, "of": true,
+	"opt": true, "over": true, "par": true, "participant": true, "rect": true,
+	"right": true, "title": true,
+}
+
+func isIdentifier(s string) bool {
+	return identifierPattern.MatchString(s) && !keywords[strings.ToLower(s)]
+}
+
 // String returns the sequence diagram messages
 func (sd *Diagram) String() string {
 	var s strings.Builder
@@ -0,0 +1,69 @@
+package sequencediagram
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReorder(t *testing.T) {
+	tests := []struct {
+		text         string
+		names        []string
+		order        []string
+		messageTypes []reflect.Type
+	}{
+		{"a->b:msg", []string{"b"}, []string{"b", "a"}, messageTypes(BackwardMessage{})},
+		{"a->b:msg", []string{"a", "b"}, []string{"a", "b"}, messageTypes(ForwardMessage{})},
+		{"a->b:req\nb-->a:resp\nc->c:self", []string{"c", "b"}, []string{"c", "b", "a"},
+			messageTypes(BackwardMessage{}, ForwardMessage{}, SelfMessage{})},
+		{"box \"g\"\nparticipant a\nparticipant b\nend box\nc->a:msg", []string{"c", "b"}, []string{"c", "b", "a"},
+			messageTypes(Box{}, Participant{}, Participant{}, EndBox{}, ForwardMessage{})},
+		{"box \"g\"\nparticipant a\nparticipant b\nend box\nc->a:msg", []string{"b", "c"}, []string{"b", "a", "c"},
+			messageTypes(Box{}, Participant{}, Participant{}, EndBox{}, BackwardMessage{})},
+	}
+	for _, test := range tests {
+		sd, err := ParseFromText(test.text)
+		if err != nil {
+			t.Errorf("TestReorder => got parse error: %v", err)
+			continue
+		}
+		if err := sd.Reorder(test.names); err != nil {
+			t.Errorf("TestReorder => got reorder error: %v", err)
+			continue
+		}
+		if got := nodeNames(sd.GetOrderedNodes()); !reflect.DeepEqual(got, test.order) {
+			t.Errorf("TestReorder => input: %q %v, expected node order %v, got %v", test.text, test.names, test.order, got)
+		}
+		for i, node := range sd.GetOrderedNodes() {
+			if node.Order != i {
+				t.Errorf("TestReorder => input: %q %v, expected node %s to have order %d, got %d", test.text, test.names, node.Name, i, node.Order)
+			}
+		}
+		for i, gotMsg := range sd.Messages() {
+			if reflect.TypeOf(gotMsg) != test.messageTypes[i] {
+				t.Errorf("TestReorder => input: %q %v, expected %v message type, got %T", test.text, test.names, test.messageTypes[i], gotMsg)
+			}
+		}
+		if sd.String() != test.text {
+			t.Errorf("TestReorder => expected %q got %q", test.text, sd)
+		}
+	}
+}
+
+func TestReorderErrors(t *testing.T) {
+	tests := []struct {
+		names []string
+	}{
+		{[]string{"c"}},
+		{[]string{"a", "a"}},
+	}
+	for _, test := range tests {
+		sd, err := ParseFromText("a->b:msg")
+		if err != nil {
+			t.Fatalf("TestReorderErrors => got parse error: %v", err)
+		}
+		if err := sd.Reorder(test.names); err == nil {
+			t.Errorf("TestReorderErrors => expected error reordering with %v", test.names)
+		}
+	}
+}
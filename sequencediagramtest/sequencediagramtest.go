@@ -0,0 +1,129 @@
+// package sequencediagramtest compares sequence diagrams with golden files in tests
+package sequencediagramtest
+
+import (
+	"bufio"
+	"flag"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/Laugusti/sequencediagram"
+	"github.com/Laugusti/sequencediagram/textdiagram"
+)
+
+// UpdateEnv is the environment variable that makes AssertMatches rewrite the
+// golden files when set to a true value (SEQUENCEDIAGRAMTEST_UPDATE=1 go test)
+const UpdateEnv = "SEQUENCEDIAGRAMTEST_UPDATE"
+
+// updating reports if the golden files are rewritten. The package does not
+// define an -update flag, so that test binaries can define their own, but one
+// defined by the test binary is honored.
+func updating() bool {
+	if f := flag.Lookup("update"); f != nil {
+		if getter, ok := f.Value.(flag.Getter); ok {
+			if v, ok := getter.Get().(bool); ok && v {
+				return true
+			}
+		}
+	}
+	v, _ := strconv.ParseBool(os.Getenv(UpdateEnv))
+	return v
+}
+
+// mark is the gutter mark of the messages that differ
+const mark = ">"
+
+// AssertMatches fails the test if got is not the sequence diagram in the golden
+// file. The golden file holds the sequence diagram text (as returned by
+// Diagram.String). Set SEQUENCEDIAGRAMTEST_UPDATE=1, or the test binary's own
+// -update flag, to write got to the golden file.
+// On mismatch the text diagrams of got and the golden file are shown side by
+// side, with the messages that differ marked in the gutter.
+func AssertMatches(t testing.TB, got *sequencediagram.Diagram, goldenPath string) {
+	t.Helper()
+	if updating() {
+		if err := ioutil.WriteFile(goldenPath, []byte(got.String()+"\n"), 0644); err != nil {
+			t.Fatalf("error updating golden file: %v", err)
+		}
+		return
+	}
+	b, err := ioutil.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("error reading golden file (set "+UpdateEnv+"=1 to create it): %v", err)
+	}
+	want, err := sequencediagram.ParseFromText(strings.TrimSpace(string(b)))
+	if err != nil {
+		t.Fatalf("error parsing golden file %s: %v", goldenPath, err)
+	}
+	if got.String() == want.String() {
+		return
+	}
+	gotMarks, wantMarks := differingMessages(got, want)
+	t.Errorf("sequence diagram does not match %s:\n%s", goldenPath, sideBySide(
+		"got", render(got, gotMarks),
+		"want", render(want, wantMarks),
+	))
+}
+
+// differingMessages marks the messages of got and want that are not in both
+func differingMessages(got, want *sequencediagram.Diagram) (map[int]string, map[int]string) {
+	gotMarks, wantMarks := make(map[int]string), make(map[int]string)
+	var g, w int
+	for _, m := range sequencediagram.Diff(want, got).Messages {
+		switch m.Op {
+		case sequencediagram.Added:
+			gotMarks[g] = mark
+			g++
+		case sequencediagram.Removed:
+			wantMarks[w] = mark
+			w++
+		case sequencediagram.Changed:
+			gotMarks[g], wantMarks[w] = mark, mark
+			g++
+			w++
+		default:
+			g++
+			w++
+		}
+	}
+	return gotMarks, wantMarks
+}
+
+// render returns the lines of the text diagram of sd
+func render(sd *sequencediagram.Diagram, marks map[int]string) []string {
+	var lines []string
+	scanner := bufio.NewScanner(textdiagram.EncodeMarked(sd, marks))
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), " "))
+	}
+	return lines
+}
+
+// sideBySide returns the left and right lines next to each other, under their headings
+func sideBySide(leftHeading string, left []string, rightHeading string, right []string) string {
+	left = append([]string{leftHeading, ""}, left...)
+	right = append([]string{rightHeading, ""}, right...)
+	var width int
+	for _, line := range left {
+		if utf8.RuneCountInString(line) > width {
+			width = utf8.RuneCountInString(line)
+		}
+	}
+	var s strings.Builder
+	for i := 0; i < len(left) || i < len(right); i++ {
+		var l, r string
+		if i < len(left) {
+			l = left[i]
+		}
+		if i < len(right) {
+			r = right[i]
+		}
+		s.WriteString(strings.TrimRight(l+strings.Repeat(" ", width-utf8.RuneCountInString(l))+" │ "+r, " "))
+		s.WriteString("\n")
+	}
+	return strings.TrimRight(s.String(), "\n")
+}
@@ -0,0 +1,124 @@
+package sequencediagramtest
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/Laugusti/sequencediagram"
+)
+
+// recordingT records the failures of a test
+type recordingT struct {
+	testing.TB
+	errors []string
+	fatal  bool
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *recordingT) Fatalf(format string, args ...interface{}) {
+	t.Errorf(format, args...)
+	t.fatal = true
+	runtime.Goexit()
+}
+
+// assertMatches runs AssertMatches in its own goroutine, so that Fatalf can
+// stop it
+func assertMatches(t *recordingT, got *sequencediagram.Diagram, goldenPath string) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		AssertMatches(t, got, goldenPath)
+	}()
+	<-done
+}
+
+func users(status string) *sequencediagram.Diagram {
+	var r sequencediagram.Recorder
+	r.Call("Client", "Server", "GET /users")
+	r.Return("Server", "Client", status)
+	return r.Diagram()
+}
+
+func TestAssertMatches(t *testing.T) {
+	rt := &recordingT{TB: t}
+	assertMatches(rt, users("200 OK"), "testdata/users.sd")
+	if len(rt.errors) > 0 {
+		t.Errorf("TestAssertMatches => expected no errors, got %v", rt.errors)
+	}
+}
+
+func TestAssertMatchesMismatch(t *testing.T) {
+	rt := &recordingT{TB: t}
+	assertMatches(rt, users("500 Internal Server Error"), "testdata/users.sd")
+	if len(rt.errors) != 1 || rt.fatal {
+		t.Fatalf("TestAssertMatchesMismatch => expected one error, got %v", rt.errors)
+	}
+	want := `sequence diagram does not match testdata/users.sd:
+got                                            │ want
+                                               │
+  ┌────────┐                        ┌────────┐ │   ┌────────┐         ┌────────┐
+  │ Client │                        │ Server │ │   │ Client │         │ Server │
+  └────────┘                        └────────┘ │   └────────┘         └────────┘
+       │  ┌────────────┐                 │     │        │  ┌────────────┐  │
+        ──┤ GET /users ├────────────────▶      │         ──┤ GET /users ├─▶
+       │  └────────────┘                 │     │        │  └────────────┘  │
+>         ┌───────────────────────────┐        │ >             ┌────────┐
+>      │◀-┤ 500 Internal Server Error ├--│     │ >      │◀-----┤ 200 OK ├--│
+>         └───────────────────────────┘        │ >             └────────┘
+       │                                 │     │        │                  │
+  ┌────────┐                        ┌────────┐ │   ┌────────┐         ┌────────┐
+  │ Client │                        │ Server │ │   │ Client │         │ Server │
+  └────────┘                        └────────┘ │   └────────┘         └────────┘`
+	if rt.errors[0] != want {
+		t.Errorf("TestAssertMatchesMismatch => got:\n%s\nwant:\n%s", rt.errors[0], want)
+	}
+}
+
+func TestAssertMatchesMissingGolden(t *testing.T) {
+	rt := &recordingT{TB: t}
+	assertMatches(rt, users("200 OK"), filepath.Join(t.TempDir(), "missing.sd"))
+	if !rt.fatal || !strings.Contains(rt.errors[0], UpdateEnv) {
+		t.Errorf("TestAssertMatchesMissingGolden => expected a fatal error mentioning %s, got %v", UpdateEnv, rt.errors)
+	}
+}
+
+// update is a test binary's own -update flag, which the package must not redefine
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+func TestAssertMatchesUpdateFlag(t *testing.T) {
+	*update = true
+	defer func() { *update = false }()
+	golden := filepath.Join(t.TempDir(), "users.sd")
+	rt := &recordingT{TB: t}
+	assertMatches(rt, users("200 OK"), golden)
+	if _, err := ioutil.ReadFile(golden); err != nil || len(rt.errors) > 0 {
+		t.Errorf("TestAssertMatchesUpdateFlag => expected the golden file to be written, got %v %v", err, rt.errors)
+	}
+}
+
+func TestAssertMatchesUpdate(t *testing.T) {
+	t.Setenv(UpdateEnv, "1")
+	golden := filepath.Join(t.TempDir(), "users.sd")
+	rt := &recordingT{TB: t}
+	assertMatches(rt, users("200 OK"), golden)
+	if len(rt.errors) > 0 {
+		t.Fatalf("TestAssertMatchesUpdate => expected no errors, got %v", rt.errors)
+	}
+	b, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("TestAssertMatchesUpdate => got error: %v", err)
+	}
+	if got, want := string(b), "Client->Server:GET /users\nServer-->Client:200 OK\n"; got != want {
+		t.Errorf("TestAssertMatchesUpdate => got %q, want %q", got, want)
+	}
+}
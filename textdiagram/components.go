@@ -32,6 +32,10 @@ const (
 
 	life_line     = "│"
 	alt_life_line = "‖"
+
+	divider_line = "═"
+
+	loop_label = "loop "
 )
 
 const (
@@ -43,6 +47,8 @@ const (
 
 	pad_before_note = " "
 	pad_after_note  = " "
+
+	divider_min_length = 2
 )
 
 // boxString wraps s in a text box, padding to padToHeight if necessary
@@ -75,6 +81,42 @@ func boxString(s string, padToHeight int) string {
 	return box
 }
 
+// groupFrameTop is the top edge of a group frame with the label, extended to
+// length runes if necessary
+func groupFrameTop(label string, length int) string {
+	top := box_top_left + box_horizontal + " " + label + " "
+	if fill := length - utf8.RuneCountInString(top+box_top_right); fill > 0 {
+		top += strings.Repeat(box_horizontal, fill)
+	}
+	return top + box_top_right
+}
+
+// groupFrameBottom is the bottom edge of a group frame with length runes, at
+// least the two corners
+func groupFrameBottom(length int) string {
+	if length < 2 {
+		length = 2
+	}
+	return box_bottom_left + strings.Repeat(box_horizontal, length-2) + box_bottom_right
+}
+
+// divider is a line of at least length runes with s in the middle, the lines of
+// s are joined with spaces
+func divider(s string, length int) string {
+	label := " " + strings.Join(strings.Split(s, "\\n"), " ") + " "
+	fill := length - utf8.RuneCountInString(label)
+	if fill < 2*divider_min_length {
+		fill = 2 * divider_min_length
+	}
+	return strings.Repeat(divider_line, fill/2) + label + strings.Repeat(divider_line, (fill+1)/2)
+}
+
+// loopFrameTop is the top edge of the frame around a loop block labelled with
+// s, the lines of s are joined with spaces
+func loopFrameTop(s string, length int) string {
+	return groupFrameTop(loop_label+strings.Join(strings.Split(s, "\\n"), " "), length)
+}
+
 // selfLoop text diagram of a arrow that loops back to self with message s
 func selfLoop(s string, altArrowBody, altArrowEnd bool) string {
 	loopTop := strings.Repeat(arrow_body, loop_body_length+1) + box_top_right
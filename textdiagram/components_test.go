@@ -81,3 +81,42 @@ func TestNoteBox(t *testing.T) {
 		}
 	}
 }
+
+func TestDivider(t *testing.T) {
+	tests := []struct {
+		text   string
+		length int
+		want   string
+	}{
+		{`abc`, 0, "══ abc ══"},
+		{`abc`, 10, "══ abc ═══"},
+		{`abc`, 12, "═══ abc ════"},
+		{`a\nb`, 9, "══ a b ══"},
+	}
+
+	for _, test := range tests {
+		got := divider(test.text, test.length)
+		if got != test.want {
+			t.Errorf("TestDivider => got wrong divider: input (text: %q length: %d), got: %q, want: %q", test.text, test.length, got, test.want)
+		}
+	}
+}
+
+func TestGroupFrameBottom(t *testing.T) {
+	tests := []struct {
+		length int
+		want   string
+	}{
+		{5, "└───┘"},
+		{2, "└┘"},
+		// frames of diagrams without participants have no width
+		{0, "└┘"},
+		{-2, "└┘"},
+	}
+
+	for _, test := range tests {
+		if got := groupFrameBottom(test.length); got != test.want {
+			t.Errorf("TestGroupFrameBottom => got wrong frame: input (length: %d), got: %q, want: %q", test.length, got, test.want)
+		}
+	}
+}
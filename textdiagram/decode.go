@@ -0,0 +1,391 @@
+package textdiagram
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Laugusti/sequencediagram"
+)
+
+// Decode creates a sequence diagram from its textual representation, as
+// created by Encode. Participants are declared in the order of the header
+// boxes and a note that fits right of one node and left of the next is read as
+// a note right of the first node.
+func Decode(r io.Reader) (*sequencediagram.Diagram, error) {
+	var lines [][]rune
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, []rune(strings.TrimRight(scanner.Text(), " ")))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	d := &decoder{}
+	for len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+		d.trailingEmpty = true
+	}
+	d.lines = lines
+	if err := d.decodeTitle(); err != nil {
+		return nil, err
+	}
+	if err := d.decodeHeaders(); err != nil {
+		return nil, err
+	}
+	if err := d.decodeMessages(); err != nil {
+		return nil, err
+	}
+	return sequencediagram.ParseFromText(strings.Join(d.text, "\n"))
+}
+
+// decoder converts a text diagram into the sequence diagram syntax
+type decoder struct {
+	lines [][]rune
+	// whether empty lines followed the last line of the text
+	trailingEmpty bool
+	// first line of the top headers, number of header lines and first line
+	// of the bottom headers
+	start, height, end int
+	// name and lifeline index of each node
+	names     []string
+	lifelines []int
+	text      []string
+	// number of loop frames that are not ended yet, nested frames are indented
+	loops int
+}
+
+// decodeTitle reads the title lines before the empty line that separates them
+// from the headers
+func (d *decoder) decodeTitle() error {
+	// the headers of a diagram without participants are empty lines
+	if len(d.lines) == 0 || len(d.lines[0]) == 0 || strings.ContainsRune(string(d.lines[0]), []rune(box_top_left)[0]) {
+		return nil
+	}
+	var title []string
+	for i, line := range d.lines {
+		if len(line) == 0 {
+			d.start = i + 1
+			d.text = append(d.text, "title "+strings.Join(title, "\\n"))
+			return nil
+		}
+		title = append(title, strings.TrimSpace(string(line)))
+	}
+	// a diagram without participants is only its title
+	if d.trailingEmpty {
+		d.start = len(d.lines)
+		d.text = append(d.text, "title "+strings.Join(title, "\\n"))
+		return nil
+	}
+	return fmt.Errorf("Line %d: Expected empty line after title.", len(d.lines))
+}
+
+// decodeHeaders reads the header boxes and group frames, the headers are
+// repeated at the end of the text diagram
+func (d *decoder) decodeHeaders() error {
+	if d.start > 0 && d.start == len(d.lines) || d.start < len(d.lines) && len(d.lines[d.start]) == 0 {
+		// no participants, the headers are empty lines and the messages can
+		// only be dividers and loop frames
+		d.end = len(d.lines)
+		return nil
+	}
+	for h := 3; d.start+2*h <= len(d.lines); h++ {
+		if equalLines(d.lines[d.start:d.start+h], d.lines[len(d.lines)-h:]) {
+			d.height = h
+			break
+		}
+	}
+	if d.height == 0 {
+		return fmt.Errorf("Line %d: Expected participant headers.", d.start+1)
+	}
+	height := d.height
+	d.end = len(d.lines) - height
+	headers := d.lines[d.start : d.start+height]
+
+	// frames around groups add a line above and below the boxes
+	var frame int
+	if height > 3 && strings.ContainsRune(string(headers[1]), []rune(box_top_left)[0]) {
+		frame = 1
+	}
+	boxes := headers[frame : height-frame]
+	type box struct {
+		begin, end int
+		name       string
+	}
+	var nodes []box
+	for _, span := range spans(boxes[0], box_top_left, box_top_right) {
+		var name []string
+		for _, line := range boxes[1 : len(boxes)-1] {
+			name = append(name, strings.TrimSpace(substring(line, span[0]+1, span[1])))
+		}
+		// boxes are padded to the height of the tallest one
+		for len(name) > 1 && name[len(name)-1] == "" {
+			name = name[:len(name)-1]
+		}
+		nodes = append(nodes, box{span[0], span[1], strings.Join(name, "\\n")})
+		d.names = append(d.names, strings.Join(name, "\\n"))
+		d.lifelines = append(d.lifelines, offset{span[0], span[1]}.getMiddle())
+	}
+
+	var groups [][2]int
+	if frame == 1 {
+		groups = spans(headers[0], box_top_left, box_top_right)
+	}
+	for i := 0; i < len(nodes); {
+		inGroup := false
+		for _, group := range groups {
+			if nodes[i].begin < group[0] || nodes[i].end > group[1] {
+				continue
+			}
+			label := strings.Trim(substring(headers[0], group[0]+1, group[1]), box_horizontal+" ")
+			d.text = append(d.text, `box "`+label+`"`)
+			for ; i < len(nodes) && nodes[i].end < group[1]; i++ {
+				d.text = append(d.text, "participant "+nodes[i].name)
+			}
+			d.text = append(d.text, "end box")
+			inGroup = true
+			break
+		}
+		if !inGroup {
+			d.text = append(d.text, "participant "+nodes[i].name)
+			i++
+		}
+	}
+	return nil
+}
+
+// decodeMessages reads the messages between the top and bottom headers
+func (d *decoder) decodeMessages() error {
+	for i := d.start + d.height; i < d.end; {
+		line := d.withoutLifelines(i)
+		var n int
+		var err error
+		switch {
+		case strings.TrimSpace(string(line)) == "":
+			n = 1
+		case strings.ContainsRune(string(line), []rune(divider_line)[0]):
+			label := strings.Trim(string(line), divider_line+" ")
+			d.text = append(d.text, "== "+label+" ==")
+			n = 1
+		case strings.HasPrefix(string(line), strings.Repeat(" ", d.loops)+box_top_left+box_horizontal+" "+loop_label):
+			label := strings.TrimPrefix(strings.Trim(string(line), box_top_left+box_top_right+box_horizontal+" "), loop_label)
+			d.text = append(d.text, "loop "+label)
+			d.loops++
+			n = 1
+		case d.loops > 0 && strings.HasPrefix(string(line), strings.Repeat(" ", d.loops-1)+box_bottom_left+box_horizontal):
+			d.text = append(d.text, "end")
+			d.loops--
+			n = 1
+		case strings.ContainsRune(string(line), []rune(alt_box_top_right)[0]):
+			n, err = d.decodeNote(i)
+		case strings.ContainsRune(string(line), []rune(box_top_left)[0]):
+			n, err = d.decodeArrow(i)
+		case strings.ContainsRune(string(line), []rune(box_top_right)[0]):
+			n, err = d.decodeSelfLoop(i)
+		default:
+			err = fmt.Errorf("Line %d: Unrecognised message.", i+1)
+		}
+		if err != nil {
+			return err
+		}
+		i += n
+	}
+	return nil
+}
+
+// decodeNote reads the note starting at line i and returns its number of lines
+func (d *decoder) decodeNote(i int) (int, error) {
+	top := d.withoutLifelines(i)
+	left, right := runeIndexOf(top, box_top_left), runeIndexOf(top, alt_box_top_right)
+	lines, err := d.boxLines(i, left, right)
+	if err != nil {
+		return 0, err
+	}
+	var note string
+	for node, lifeline := range d.lifelines {
+		if left == lifeline+utf8Len(life_line+pad_before_note) {
+			note = "note right of "
+		} else if right == lifeline-utf8Len(pad_before_note+life_line) || (node == 0 && left == utf8Len(pad_before_note)) {
+			note = "note left of "
+		} else {
+			continue
+		}
+		d.text = append(d.text, note+d.names[node]+":"+strings.Join(lines, "\\n"))
+		return len(lines) + 2, nil
+	}
+	return 0, fmt.Errorf("Line %d: Note is not next to a participant.", i+1)
+}
+
+// decodeArrow reads the message from one node to another starting at line i
+// and returns its number of lines
+func (d *decoder) decodeArrow(i int) (int, error) {
+	if i+1 >= d.end {
+		return 0, fmt.Errorf("Line %d: Message is missing an arrow.", i+1)
+	}
+	arrow := d.withoutLifelines(i + 1)
+	left, right := runeIndexOf(arrow, box_arrow_left), runeIndexOf(arrow, box_arrow_right)
+	if left < 0 || right < left {
+		return 0, fmt.Errorf("Line %d: Message is missing an arrow.", i+2)
+	}
+	lines, err := d.boxLines(i, left, right)
+	if err != nil {
+		return 0, err
+	}
+	before := strings.TrimLeft(substring(arrow, 0, left), " ")
+	after := strings.TrimRight(substring(arrow, right+1, len(arrow)), " ")
+	beforeStart := left - utf8Len(before)
+	afterEnd := right + utf8Len(after)
+
+	var from, to int
+	var altArrowBody, altArrowEnd bool
+	switch {
+	case strings.HasSuffix(after, arrow_forward_end) || strings.HasSuffix(after, alt_arrow_forward_end):
+		from, to = d.nodeAt(beforeStart-1), d.nodeAt(afterEnd+1)
+		altArrowBody = strings.HasPrefix(before, alt_arrow_start)
+		altArrowEnd = strings.HasSuffix(after, alt_arrow_forward_end)
+	case strings.HasPrefix(before, arrow_backward_end) || strings.HasPrefix(before, alt_arrow_backward_end):
+		from, to = d.nodeAt(afterEnd+1), d.nodeAt(beforeStart-1)
+		altArrowBody = strings.HasPrefix(after, alt_arrow_start)
+		altArrowEnd = strings.HasPrefix(before, alt_arrow_backward_end)
+	default:
+		return 0, fmt.Errorf("Line %d: Message is missing an arrow head.", i+2)
+	}
+	if from < 0 || to < 0 {
+		return 0, fmt.Errorf("Line %d: Message does not connect two participants.", i+2)
+	}
+	d.text = append(d.text, d.names[from]+arrowType(altArrowBody, altArrowEnd)+d.names[to]+":"+strings.Join(lines, "\\n"))
+	return len(lines) + 2, nil
+}
+
+// decodeSelfLoop reads the message from a node to itself starting at line i
+// and returns its number of lines
+func (d *decoder) decodeSelfLoop(i int) (int, error) {
+	top := d.withoutLifelines(i)
+	node := d.nodeAt(len(top) - utf8Len(strings.TrimLeft(string(top), " ")) - 1)
+	if node < 0 {
+		return 0, fmt.Errorf("Line %d: Message does not start at a participant.", i+1)
+	}
+	// text starts after the loop and ends before the next lifeline
+	begin := d.lifelines[node] + utf8Len(life_line) + loop_body_length + 1 + utf8Len(arrow_vertical+pad_between_loop_and_message)
+	end := -1
+	if node+1 < len(d.lifelines) {
+		end = d.lifelines[node+1]
+	}
+	var lines []string
+	for j := i + 1; j < d.end; j++ {
+		line := d.withoutLifelines(j)
+		if !strings.ContainsRune(string(line), []rune(box_bottom_right)[0]) {
+			stop := len(line)
+			if end >= 0 && end < stop {
+				stop = end
+			}
+			lines = append(lines, strings.TrimRight(substring(line, begin, stop), " "))
+			continue
+		}
+		altArrowBody := strings.Contains(string(top), alt_arrow_body)
+		altArrowEnd := strings.HasPrefix(strings.TrimSpace(string(line)), alt_arrow_backward_end)
+		d.text = append(d.text, d.names[node]+arrowType(altArrowBody, altArrowEnd)+d.names[node]+":"+strings.Join(lines, "\\n"))
+		return j - i + 1, nil
+	}
+	return 0, fmt.Errorf("Line %d: Message loop is never closed.", i+1)
+}
+
+// boxLines returns the text in the box with left and right walls at the given
+// indexes, starting at line i
+func (d *decoder) boxLines(i, left, right int) ([]string, error) {
+	var lines []string
+	for j := i + 1; j < d.end; j++ {
+		line := d.withoutLifelines(j)
+		if left < len(line) && string(line[left]) == box_bottom_left {
+			return lines, nil
+		}
+		lines = append(lines, strings.TrimSpace(substring(line, left+1, right)))
+	}
+	return nil, fmt.Errorf("Line %d: Box is never closed.", i+1)
+}
+
+// withoutLifelines returns line i with the lifelines replaced by spaces
+func (d *decoder) withoutLifelines(i int) []rune {
+	line := append([]rune(nil), d.lines[i]...)
+	for _, lifeline := range d.lifelines {
+		if lifeline < len(line) && string(line[lifeline]) == life_line {
+			line[lifeline] = ' '
+		}
+	}
+	return line
+}
+
+// nodeAt returns the node with the lifeline at index, or -1 if there is none
+func (d *decoder) nodeAt(index int) int {
+	for node, lifeline := range d.lifelines {
+		if lifeline == index {
+			return node
+		}
+	}
+	return -1
+}
+
+// arrowType returns the arrow of the sequence diagram syntax
+func arrowType(altArrowBody, altArrowEnd bool) string {
+	arrow := "->"
+	if altArrowBody {
+		arrow = "-" + arrow
+	}
+	if altArrowEnd {
+		arrow += ">"
+	}
+	return arrow
+}
+
+// spans returns the index pairs of each begin rune and the following end rune
+func spans(line []rune, begin, end string) [][2]int {
+	var result [][2]int
+	start := -1
+	for i, r := range line {
+		switch string(r) {
+		case begin:
+			start = i
+		case end:
+			if start >= 0 {
+				result = append(result, [2]int{start, i})
+				start = -1
+			}
+		}
+	}
+	return result
+}
+
+// substring returns the runes of line from begin up to end
+func substring(line []rune, begin, end int) string {
+	if end > len(line) {
+		end = len(line)
+	}
+	if begin >= end {
+		return ""
+	}
+	return string(line[begin:end])
+}
+
+// runeIndexOf returns the index of the first rune s in line, or -1
+func runeIndexOf(line []rune, s string) int {
+	for i, r := range line {
+		if string(r) == s {
+			return i
+		}
+	}
+	return -1
+}
+
+func equalLines(a, b [][]rune) bool {
+	for i := range a {
+		if string(a[i]) != string(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func utf8Len(s string) int {
+	return len([]rune(s))
+}
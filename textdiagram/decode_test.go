@@ -0,0 +1,79 @@
+package textdiagram
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Laugusti/sequencediagram"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		text string
+	}{
+		{readFile(t, "testdata/test1_sd.txt")},
+		{readFile(t, "testdata/test2_sd.txt")},
+		{readFile(t, "testdata/test3_sd.txt")},
+		{readFile(t, "testdata/test4_sd.txt")},
+		{readFile(t, "testdata/test5_sd.txt")},
+		{"title Making a request\nClient->Server:Request\nServer->Server:Redirect\nServer->Database:Query\nDatabase-->Server:Result\nnote right of Server:Do Stuff\nServer->Client:Response"},
+		{"title Multi\\nline\nparticipant a\\nb\na->a:x\na-->a:y\na->>a:z\na-->>a:w\na->>b:m\\nl\nb-->>a:n\nnote left of a:left\nnote left of b:left\\nnote\nc->c:self\\nloop"},
+		{"participant a\nparticipant b\n== one ==\na->b:msg\n== a long divider label =="},
+		{"a->b:x\nloop one\nloop two\nloop three\nb-->a:y\nend\nend\na->a:z\nend"},
+		// diagrams without participants
+		{"title T"},
+		{"title Multi\\nline"},
+		{"== d =="},
+		{"title T\n== d =="},
+		{"loop l\nend"},
+		{"title T\nloop l\n== x ==\nloop m\nend\nend"},
+	}
+	for _, test := range tests {
+		want := getAsTextDiagram(t, test.text)
+		sd, err := Decode(strings.NewReader(want))
+		if err != nil {
+			t.Errorf("TestDecode => input: %q, got error: %v", test.text, err)
+			continue
+		}
+		var b bytes.Buffer
+		io.Copy(&b, Encode(sd))
+		if got := b.String(); got != want {
+			t.Errorf("TestDecode => input: %q, decoded: %q\\n got:\\n%s\\nwant:\\n%s", test.text, sd, got, want)
+		}
+	}
+}
+
+func TestDecodeMessages(t *testing.T) {
+	sd, err := sequencediagram.ParseFromText("a->b:req\nb-->>a:resp\nnote right of a:n\na->a:self")
+	if err != nil {
+		t.Fatalf("error parsing sequence diagram: %v", err)
+	}
+	decoded, err := Decode(Encode(sd))
+	if err != nil {
+		t.Fatalf("TestDecodeMessages => got error: %v", err)
+	}
+	want := "participant a\nparticipant b\na->b:req\nb-->>a:resp\nnote right of a:n\na->a:self"
+	if got := decoded.String(); got != want {
+		t.Errorf("TestDecodeMessages => got %q, want %q", got, want)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Title", "Line 1: Expected empty line after title."},
+		{"Title\n\n┌───┐", "Line 3: Expected participant headers."},
+		{"┌───┐\n│ a │\n└───┘", "Line 1: Expected participant headers."},
+		{"┌───┐\n│ a │\n└───┘\n  x\n┌───┐\n│ a │\n└───┘", "Line 4: Unrecognised message."},
+	}
+	for _, test := range tests {
+		_, err := Decode(strings.NewReader(test.text))
+		if err == nil || err.Error() != test.want {
+			t.Errorf("TestDecodeErrors => input: %q, got error %v, want %q", test.text, err, test.want)
+		}
+	}
+}
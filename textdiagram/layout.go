@@ -0,0 +1,200 @@
+package textdiagram
+
+import (
+	"github.com/Laugusti/sequencediagram"
+)
+
+// Layout is the criteria Optimize uses to compare participant orders
+type Layout int
+
+const (
+	// MinimumWidth prefers the order with the narrowest text diagram
+	MinimumWidth Layout = iota
+	// MinimumArrowSpan prefers the order with the shortest total arrow length
+	MinimumArrowSpan
+)
+
+// Optimize reorders the participants of the sequence diagram that were only
+// declared implicitly (by a message or note) to minimise the width or the total
+// arrow span of the text diagram. Participants declared with a participant
+// statement keep their relative order. The current order is kept unless the
+// new one has a lower cost, so the result is never worse than the input and
+// optimizing an optimized diagram does not change it.
+func Optimize(sd *sequencediagram.Diagram, layout Layout) error {
+	original := sd.GetOrderedNodes()
+	originalCost := calcLayoutCost(sd, layout)
+	declared := make(map[*sequencediagram.Node]bool)
+	for _, message := range sd.Messages() {
+		if p, ok := message.(sequencediagram.Participant); ok {
+			declared[p.Self] = true
+		}
+	}
+	var order []*sequencediagram.Node
+	for _, node := range sd.GetOrderedNodes() {
+		if declared[node] {
+			order = append(order, node)
+		}
+	}
+	// placing free nodes in order of first appearance makes the result
+	// independent of their current order
+	var free []*sequencediagram.Node
+	for _, node := range firstAppearance(sd) {
+		if !declared[node] {
+			free = append(free, node)
+		}
+	}
+	if len(free) == 0 {
+		return nil
+	}
+
+	// cost of an order, unplaced free nodes stay at the end
+	cost := func(order []*sequencediagram.Node, unplaced []*sequencediagram.Node) (layoutCost, error) {
+		if err := sd.Reorder(nodeNames(append(append([]*sequencediagram.Node{}, order...), unplaced...))); err != nil {
+			return layoutCost{}, err
+		}
+		return calcLayoutCost(sd, layout), nil
+	}
+
+	// insert each free node at its best position
+	for i, node := range free {
+		var err error
+		if order, err = bestInsert(order, node, free[i+1:], cost); err != nil {
+			return err
+		}
+	}
+
+	// move free nodes to their best position until the cost stops improving,
+	// each pass must improve the cost so this always terminates
+	best, err := cost(order, nil)
+	if err != nil {
+		return err
+	}
+	for improved := true; improved; {
+		improved = false
+		for _, node := range free {
+			candidate, err := bestInsert(removeNode(order, node), node, nil, cost)
+			if err != nil {
+				return err
+			}
+			c, err := cost(candidate, nil)
+			if err != nil {
+				return err
+			}
+			if c.less(best) {
+				order, best, improved = candidate, c, true
+			}
+		}
+	}
+	if !best.less(originalCost) {
+		order = original
+	}
+	return sd.Reorder(nodeNames(order))
+}
+
+// layoutCost compares by primary, then by secondary
+type layoutCost struct {
+	primary   int
+	secondary int
+}
+
+func (c layoutCost) less(other layoutCost) bool {
+	if c.primary != other.primary {
+		return c.primary < other.primary
+	}
+	return c.secondary < other.secondary
+}
+
+// calcLayoutCost calculates the cost of the current order of the sequence diagram
+func calcLayoutCost(sd *sequencediagram.Diagram, layout Layout) layoutCost {
+	offsets := calcOffsets(sd)
+	var width int
+	if len(offsets) > 0 {
+		width = offsets[len(offsets)-1].end + 1
+	}
+	for _, group := range nonEmptyGroups(sd.Groups()) {
+		if _, right := groupBounds(group, offsets); right+1 > width {
+			width = right + 1
+		}
+	}
+	var span int
+	for _, message := range sd.Messages() {
+		switch message := message.(type) {
+		case sequencediagram.ForwardMessage:
+			span += offsets[message.To.Order].getMiddle() - offsets[message.From.Order].getMiddle()
+		case sequencediagram.BackwardMessage:
+			span += offsets[message.From.Order].getMiddle() - offsets[message.To.Order].getMiddle()
+		}
+	}
+	if layout == MinimumArrowSpan {
+		return layoutCost{span, width}
+	}
+	return layoutCost{width, span}
+}
+
+// bestInsert returns order with node inserted at the position with the lowest
+// cost, the first position wins ties
+func bestInsert(order []*sequencediagram.Node, node *sequencediagram.Node, unplaced []*sequencediagram.Node,
+	cost func([]*sequencediagram.Node, []*sequencediagram.Node) (layoutCost, error)) ([]*sequencediagram.Node, error) {
+	var best []*sequencediagram.Node
+	var bestCost layoutCost
+	for i := 0; i <= len(order); i++ {
+		candidate := make([]*sequencediagram.Node, 0, len(order)+1)
+		candidate = append(append(append(candidate, order[:i]...), node), order[i:]...)
+		c, err := cost(candidate, unplaced)
+		if err != nil {
+			return nil, err
+		}
+		if best == nil || c.less(bestCost) {
+			best, bestCost = candidate, c
+		}
+	}
+	return best, nil
+}
+
+// firstAppearance returns the nodes in the order they first appear in the messages
+func firstAppearance(sd *sequencediagram.Diagram) []*sequencediagram.Node {
+	var nodes []*sequencediagram.Node
+	seen := make(map[*sequencediagram.Node]bool)
+	add := func(node *sequencediagram.Node) {
+		if !seen[node] {
+			seen[node] = true
+			nodes = append(nodes, node)
+		}
+	}
+	for _, message := range sd.Messages() {
+		switch message := message.(type) {
+		case sequencediagram.Participant:
+			add(message.Self)
+		case sequencediagram.SelfMessage:
+			add(message.Self)
+		case sequencediagram.ForwardMessage:
+			add(message.From)
+			add(message.To)
+		case sequencediagram.BackwardMessage:
+			add(message.From)
+			add(message.To)
+		case sequencediagram.Note:
+			add(message.Node)
+		}
+	}
+	return nodes
+}
+
+// removeNode returns a copy of nodes without node
+func removeNode(nodes []*sequencediagram.Node, node *sequencediagram.Node) []*sequencediagram.Node {
+	var removed []*sequencediagram.Node
+	for _, n := range nodes {
+		if n != node {
+			removed = append(removed, n)
+		}
+	}
+	return removed
+}
+
+func nodeNames(nodes []*sequencediagram.Node) []string {
+	var names []string
+	for _, node := range nodes {
+		names = append(names, node.Name)
+	}
+	return names
+}
@@ -0,0 +1,82 @@
+package textdiagram
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Laugusti/sequencediagram"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		text   string
+		layout Layout
+		want   []string
+	}{
+		{"a->c:msg\nb->c:msg", MinimumArrowSpan, []string{"a", "c", "b"}},
+		{"a->c:msg\nb->c:msg", MinimumWidth, []string{"c", "a", "b"}},
+		{"participant b\nparticipant a\nc->a:msg\nc->b:msg", MinimumArrowSpan, []string{"b", "c", "a"}},
+		{"participant a\nparticipant b\na->b:msg", MinimumWidth, []string{"a", "b"}},
+		{"a->b:a very long message\nc->d:msg\nd->c:msg", MinimumWidth, []string{"b", "c", "a", "d"}},
+		{"box \"g\"\nparticipant a\nparticipant b\nend box\nc->b:msg", MinimumArrowSpan, []string{"c", "a", "b"}},
+	}
+	for _, test := range tests {
+		sd, err := sequencediagram.ParseFromText(test.text)
+		if err != nil {
+			t.Fatalf("error parsing sequence diagram: %v", err)
+		}
+		if err := Optimize(sd, test.layout); err != nil {
+			t.Errorf("TestOptimize => input: %q, got error: %v", test.text, err)
+			continue
+		}
+		if got := nodeNames(sd.GetOrderedNodes()); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("TestOptimize => input: %q, got order %v, want %v", test.text, got, test.want)
+		}
+	}
+}
+
+func TestOptimizeNeverWorse(t *testing.T) {
+	// a chain of 30 participants declared in the worst order
+	var lines []string
+	for i := 0; i < 30; i++ {
+		lines = append(lines, fmt.Sprintf("n%02d->n%02d:msg", i, 29-i))
+	}
+	tests := []string{
+		strings.Join(lines, "\n"),
+		// declared participants keep their relative order, which can leave
+		// nothing to improve
+		"participant c\nparticipant a\nparticipant b\na->b:x\nb->c:y\nc->d:z",
+		"participant long participant name\nparticipant b\nb->a:x\na->long participant name:y",
+		"a->b:x\nb->c:y",
+	}
+	for _, text := range tests {
+		for _, layout := range []Layout{MinimumWidth, MinimumArrowSpan} {
+			sd, err := sequencediagram.ParseFromText(text)
+			if err != nil {
+				t.Fatalf("error parsing sequence diagram: %v", err)
+			}
+			before, beforeOrder := calcLayoutCost(sd, layout), nodeNames(sd.GetOrderedNodes())
+			if err := Optimize(sd, layout); err != nil {
+				t.Fatalf("TestOptimizeNeverWorse => got error: %v", err)
+			}
+			after, order := calcLayoutCost(sd, layout), nodeNames(sd.GetOrderedNodes())
+			// the order only changes if the cost is lower
+			if !after.less(before) && !reflect.DeepEqual(order, beforeOrder) {
+				t.Errorf("TestOptimizeNeverWorse => input %q, layout %d: changed order %v to %v, cost %v to %v",
+					text, layout, beforeOrder, order, before, after)
+			}
+			if before.less(after) {
+				t.Errorf("TestOptimizeNeverWorse => input %q, layout %d got worse: before %v, after %v", text, layout, before, after)
+			}
+			// optimizing again must give the same order
+			if err := Optimize(sd, layout); err != nil {
+				t.Fatalf("TestOptimizeNeverWorse => got error: %v", err)
+			}
+			if got := nodeNames(sd.GetOrderedNodes()); !reflect.DeepEqual(got, order) {
+				t.Errorf("TestOptimizeNeverWorse => input %q, layout %d is not stable: got %v, then %v", text, layout, order, got)
+			}
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package textdiagram
 
 import (
+	"sort"
 	"strings"
 	"unicode/utf8"
 
@@ -35,6 +36,27 @@ func calcOffsets(sd *sequencediagram.Diagram) []offset {
 		offsets[i] = offset{begin, end}
 	}
 
+	// reserve space for the frame edges of each group, from left to right
+	groups := nonEmptyGroups(sd.Groups())
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Nodes[0].Order < groups[j].Nodes[0].Order })
+	prevRight := -1
+	for _, group := range groups {
+		// left edge goes in the column after the previous node or frame
+		first := group.Nodes[0].Order
+		leftEdge := prevRight + 1
+		if first > 0 && offsets[first-1].end >= leftEdge {
+			leftEdge = offsets[first-1].end + 1
+		}
+		shiftOffsets(offsets, first, leftEdge+1-offsets[first].begin)
+
+		// right edge (which may be pushed out by the label) goes before the next node
+		last := group.Nodes[len(group.Nodes)-1].Order
+		_, prevRight = groupBounds(group, offsets)
+		if last+1 < len(offsets) {
+			shiftOffsets(offsets, last+1, prevRight+1-offsets[last+1].begin)
+		}
+	}
+
 	// adjust offsets based on message
 	for _, message := range sd.Messages() {
 		// calculate begining node index to start shifting. do nothing if shift is past last node
@@ -43,19 +65,45 @@ func calcOffsets(sd *sequencediagram.Diagram) []offset {
 			continue
 		}
 
-		// calculate required shift, do nothing if shift is not required
-		shift := calcShift(message, offsets)
-		if shift < 1 {
-			continue
-		}
+		// calculate required shift and shift offsets
+		shiftOffsets(offsets, shiftStart, calcShift(message, offsets))
+	}
+	return offsets
+}
+
+// shiftOffsets shifts all offsets starting at index start, does nothing if
+// shift is not positive
+func shiftOffsets(offsets []offset, start, shift int) {
+	if shift < 1 {
+		return
+	}
+	for i := start; i < len(offsets); i++ {
+		offsets[i].begin += shift
+		offsets[i].end += shift
+	}
+}
 
-		// shift offsets
-		for i := shiftStart; i < len(offsets); i++ {
-			offsets[i].begin += shift
-			offsets[i].end += shift
+// nonEmptyGroups returns the groups that have at least one member
+func nonEmptyGroups(groups []*sequencediagram.Group) []*sequencediagram.Group {
+	var nonEmpty []*sequencediagram.Group
+	for _, group := range groups {
+		if len(group.Nodes) > 0 {
+			nonEmpty = append(nonEmpty, group)
 		}
 	}
-	return offsets
+	return nonEmpty
+}
+
+// groupBounds returns the index of the left and right edges of the frame drawn
+// around the group's header boxes
+func groupBounds(group *sequencediagram.Group, offsets []offset) (int, int) {
+	left := offsets[group.Nodes[0].Order].begin - 1
+	right := offsets[group.Nodes[len(group.Nodes)-1].Order].end + 1
+	// frame must be wide enough for its label
+	if minRight := left + utf8.RuneCountInString(groupFrameTop(group.Name, 0)) - 1; minRight > right {
+		right = minRight
+	}
+	return left, right
 }
 
 // calcShiftStartIndex calculates from which node to start shifting offsets based on the message
@@ -0,0 +1,128 @@
+package textdiagram
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Laugusti/sequencediagram"
+	"github.com/Laugusti/sequencediagram/internal/cells"
+)
+
+const (
+	// each character of the text diagram is drawn in a cell, box drawing
+	// lines meet in the center of the cell
+	svg_cell_width  = 8
+	svg_cell_height = 16
+	svg_font_size   = 13
+	svg_baseline    = 12
+)
+
+// EncodeSVG creates an SVG image of a sequence diagram. The image uses the
+// layout of the text diagram created by Encode, with the box drawing
+// characters and the arrows drawn as lines.
+func EncodeSVG(sd *sequencediagram.Diagram) io.Reader {
+	b, _ := ioutil.ReadAll(Encode(sd))
+	rows, width := cells.Grid(string(b))
+
+	var solid, dashed, filled strings.Builder
+	var text bytes.Buffer
+	for row, line := range rows {
+		y := row * svg_cell_height
+		// run of runes that are drawn as text, flushed at each shape
+		var run []rune
+		runStart := 0
+		flush := func() {
+			s := strings.TrimRight(string(run), " ")
+			trimmed := strings.TrimLeft(s, " ")
+			if trimmed != "" {
+				x := (runStart + utf8.RuneCountInString(s) - utf8.RuneCountInString(trimmed)) * svg_cell_width
+				fmt.Fprintf(&text, `<text x="%d" y="%d" textLength="%d" lengthAdjust="spacingAndGlyphs">`,
+					x, y+svg_baseline, utf8.RuneCountInString(trimmed)*svg_cell_width)
+				xml.EscapeText(&text, []byte(trimmed))
+				text.WriteString("</text>\n")
+			}
+			run = nil
+		}
+		for col, shape := range cells.Row(line) {
+			if shape == nil {
+				run = append(run, line[col])
+				continue
+			}
+			flush()
+			runStart = col + 1
+			svgShape(&solid, &dashed, &filled, col*svg_cell_width, y, shape)
+		}
+		flush()
+	}
+
+	var svg strings.Builder
+	w, h := width*svg_cell_width, len(rows)*svg_cell_height
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", w, h, w, h)
+	fmt.Fprintf(&svg, `<rect width="%d" height="%d" fill="white"/>`+"\n", w, h)
+	if solid.Len() > 0 {
+		fmt.Fprintf(&svg, `<path d="%s" stroke="black" fill="none"/>`+"\n", strings.TrimSpace(solid.String()))
+	}
+	if dashed.Len() > 0 {
+		fmt.Fprintf(&svg, `<path d="%s" stroke="black" stroke-dasharray="3,2" fill="none"/>`+"\n", strings.TrimSpace(dashed.String()))
+	}
+	if filled.Len() > 0 {
+		fmt.Fprintf(&svg, `<path d="%s" fill="black"/>`+"\n", strings.TrimSpace(filled.String()))
+	}
+	fmt.Fprintf(&svg, `<g font-family="monospace" font-size="%d" xml:space="preserve">`+"\n", svg_font_size)
+	svg.Write(text.Bytes())
+	svg.WriteString("</g>\n</svg>\n")
+	return strings.NewReader(svg.String())
+}
+
+// svgShape adds the paths of the shape in the cell with top left corner x, y
+func svgShape(solid, dashed, filled *strings.Builder, x, y int, shape *cells.Shape) {
+	cx, cy := x+svg_cell_width/2, y+svg_cell_height/2
+	right, bottom := x+svg_cell_width, y+svg_cell_height
+	points := map[cells.Point][2]int{
+		cells.Center: {cx, cy},
+		cells.Left:   {x, cy},
+		cells.Right:  {right, cy},
+		cells.Top:    {cx, y},
+		cells.Bottom: {cx, bottom},
+	}
+	for _, segment := range shape.Segments {
+		from, to := points[segment.From], points[segment.To]
+		switch segment.Style {
+		case cells.Solid:
+			svgSegment(solid, from, to, 0)
+		case cells.Dashed:
+			svgSegment(dashed, from, to, 0)
+		case cells.Double:
+			svgSegment(solid, from, to, -2)
+			svgSegment(solid, from, to, 2)
+		}
+	}
+	if shape.Fold {
+		fmt.Fprintf(solid, "M%d %dV%dH%d ", cx-4, cy, cy+4, cx)
+	}
+	switch shape.Head {
+	case cells.FilledRight:
+		fmt.Fprintf(filled, "M%d %dL%d %dL%d %dZ ", x, cy-4, right, cy, x, cy+4)
+	case cells.FilledLeft:
+		fmt.Fprintf(filled, "M%d %dL%d %dL%d %dZ ", right, cy-4, x, cy, right, cy+4)
+	case cells.OpenRight:
+		fmt.Fprintf(solid, "M%d %dL%d %dL%d %d M%d %dH%d ", x, cy-4, right, cy, x, cy+4, x, cy, right)
+	case cells.OpenLeft:
+		fmt.Fprintf(solid, "M%d %dL%d %dL%d %d M%d %dH%d ", right, cy-4, x, cy, right, cy+4, x, cy, right)
+	}
+}
+
+// svgSegment adds the horizontal or vertical line from one point to the other,
+// moved by offset across its direction
+func svgSegment(path *strings.Builder, from, to [2]int, offset int) {
+	if from[1] == to[1] {
+		fmt.Fprintf(path, "M%d %dH%d ", from[0], from[1]+offset, to[0])
+		return
+	}
+	fmt.Fprintf(path, "M%d %dV%d ", from[0]+offset, from[1], to[1])
+}
@@ -0,0 +1,93 @@
+package textdiagram
+
+import (
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Laugusti/sequencediagram"
+)
+
+func TestEncodeSVG(t *testing.T) {
+	sd, err := sequencediagram.ParseFromText("title <T&C>\nClient->Server:GET /a b\nServer-->Client:200\nServer-->Server:s")
+	if err != nil {
+		t.Fatalf("error parsing sequence diagram: %v", err)
+	}
+	b, err := ioutil.ReadAll(EncodeSVG(sd))
+	if err != nil {
+		t.Fatalf("error reading svg: %v", err)
+	}
+	svg := string(b)
+
+	// the svg is well formed and has the text of the diagram
+	var texts []string
+	decoder := xml.NewDecoder(strings.NewReader(svg))
+	var inText bool
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("TestEncodeSVG => invalid xml: %v\n%s", err, svg)
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			inText = token.Name.Local == "text"
+		case xml.CharData:
+			if inText {
+				texts = append(texts, string(token))
+			}
+		case xml.EndElement:
+			inText = false
+		}
+	}
+	// dashed arrows are drawn as lines, only the labels are text
+	want := []string{"<T&C>", "Client", "Server", "GET /a b", "200", "s", "Client", "Server"}
+	if strings.Join(texts, "|") != strings.Join(want, "|") {
+		t.Errorf("TestEncodeSVG => got text %q, want %q", texts, want)
+	}
+
+	// the size is the size of the text diagram in cells
+	lines := strings.Split(getAsTextDiagram(t, "title <T&C>\nClient->Server:GET /a b\nServer-->Client:200\nServer-->Server:s"), "\n")
+	var width int
+	for _, line := range lines {
+		if n := len([]rune(line)); n > width {
+			width = n
+		}
+	}
+	if !strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="`+strconv.Itoa(width*svg_cell_width)+`" height="`+strconv.Itoa(len(lines)*svg_cell_height)+`"`) {
+		t.Errorf("TestEncodeSVG => unexpected size for %dx%d cells: %s", width, len(lines), svg[:strings.Index(svg, "\n")])
+	}
+	for _, part := range []string{`stroke="black" fill="none"`, `stroke-dasharray`, `fill="black"`} {
+		if !strings.Contains(svg, part) {
+			t.Errorf("TestEncodeSVG => expected svg to contain %q", part)
+		}
+	}
+}
+
+func TestEncodeSVGDashedArrow(t *testing.T) {
+	sd, err := sequencediagram.ParseFromText("a-->b:x-y\nb-->>a:z")
+	if err != nil {
+		t.Fatalf("error parsing sequence diagram: %v", err)
+	}
+	b, _ := ioutil.ReadAll(EncodeSVG(sd))
+	svg := string(b)
+	// the arrow bodies are the only dashed lines, the labels keep their dashes
+	dashed := regexp.MustCompile(`<path d="([^"]*)" stroke="black" stroke-dasharray`).FindStringSubmatch(svg)
+	if dashed == nil || strings.Contains(dashed[1], "V") || len(strings.Fields(dashed[1])) == 0 {
+		t.Errorf("TestEncodeSVGDashedArrow => expected a dashed path of horizontal arrow bodies, got:\n%s", svg)
+	}
+	for _, text := range []string{">x-y</text>", ">z</text>"} {
+		if !strings.Contains(svg, text) {
+			t.Errorf("TestEncodeSVGDashedArrow => expected svg to contain %q, got:\n%s", text, svg)
+		}
+	}
+	if strings.Contains(svg, ">-") || strings.Contains(svg, "&lt;</text>") || strings.Contains(svg, "&gt;</text>") {
+		t.Errorf("TestEncodeSVGDashedArrow => expected no arrow glyphs in the text, got:\n%s", svg)
+	}
+}
@@ -14,28 +14,61 @@ type textDiagram struct {
 	lifelineToggle bool
 	text           string
 	title          string
+	// length of the frame of each loop that is not ended yet
+	loops []int
+	// width of the gutter left of the diagram, 0 if there is none
+	gutter int
 }
 
 // Encode creates an textual representation a sequence diagram using the
 // provided sequence diagram
 func Encode(sd *sequencediagram.Diagram) io.Reader {
+	return EncodeMarked(sd, nil)
+}
+
+// EncodeMarked is like Encode with a gutter left of the diagram, the lines of
+// the i-th message of sd.Messages() start with marks[i]. There is no gutter
+// if marks is empty.
+func EncodeMarked(sd *sequencediagram.Diagram, marks map[int]string) io.Reader {
 	td := &textDiagram{}
 	td.offsets = calcOffsets(sd)
 	td.lifelineToggle = true
+	for _, mark := range marks {
+		if utf8.RuneCountInString(mark)+1 > td.gutter {
+			td.gutter = utf8.RuneCountInString(mark) + 1
+		}
+	}
 
 	nodes := sd.GetOrderedNodes()
-	td.addHeaders(nodes, true)
-	for _, message := range sd.Messages() {
-		td.addMessage(message)
+	td.addHeaders(nodes, sd.Groups(), true)
+	for i, message := range sd.Messages() {
+		td.addMessage(message, marks[i])
 	}
 	if td.lifelineToggle {
 		td.drawFullLifeline()
 	}
-	td.addHeaders(nodes, false)
+	td.addHeaders(nodes, sd.Groups(), false)
 	fixTitle(td)
 	return strings.NewReader(td.text)
 }
 
+// EncodeDiff creates a textual representation of the merged sequence diagram
+// of the diff, the added messages are marked with "+" and the removed messages
+// with "-" in the gutter
+func EncodeDiff(d *sequencediagram.DiagramDiff) io.Reader {
+	sd, ops := d.Merged()
+	marks := make(map[int]string)
+	for i, op := range ops {
+		switch op {
+		case sequencediagram.Added:
+			marks[i] = "+"
+		case sequencediagram.Removed:
+			marks[i] = "-"
+		}
+	}
+	return EncodeMarked(sd, marks)
+}
+
 func fixTitle(td *textDiagram) {
 	if td.title == "" {
 		return
@@ -49,29 +82,49 @@ func fixTitle(td *textDiagram) {
 			title[i] = symmetricPadToLength(title[i], ' ', length)
 		}
 	}
+	for i := range title {
+		title[i] = td.margin("") + title[i]
+	}
 	td.text = strings.Join(title, "\n") + "\n\n" + td.text
 }
 
-// addHeaders add the Node slice as text to the ascii diagram
-func (td *textDiagram) addHeaders(nodes []*sequencediagram.Node, newline bool) {
+// addHeaders add the Node slice as text to the ascii diagram, with a frame
+// around the header boxes of each group
+func (td *textDiagram) addHeaders(nodes []*sequencediagram.Node, groups []*sequencediagram.Group, newline bool) {
 	// get max # of lines in Node Name
 	height := headerBoxHeight(nodes)
+	// frames need an extra line above and below the boxes
+	groups = nonEmptyGroups(groups)
+	var frame int
+	if len(groups) > 0 {
+		frame = 1
+	}
 	// headers will have max + 2 lines (top + #lines + bottom)
-	headers := make([]string, height+2)
+	headers := make([]string, height+2+2*frame)
 	for i, node := range nodes {
-		var pad string
-		if i == 0 {
-			pad = strings.Repeat(" ", td.offsets[i].begin)
-		} else {
-			// add padding using pre-calculated node offsets
-			pad = strings.Repeat(" ", td.offsets[i].begin-td.offsets[i-1].end-1)
-		}
-		// add each line of box to header slice with padding
+		// add each line of box to header slice using pre-calculated node offsets
 		box := boxString(node.Name, height)
 		for j, line := range strings.Split(box, "\n") {
-			headers[j] += pad + line
+			headers[j+frame] = writeAtRuneIndex(headers[j+frame], td.offsets[i].begin, line)
 		}
 	}
+	for _, group := range groups {
+		left, right := groupBounds(group, td.offsets)
+		for j := range headers {
+			switch j {
+			case 0:
+				headers[j] = writeAtRuneIndex(headers[j], left, groupFrameTop(group.Name, right-left+1))
+			case len(headers) - 1:
+				headers[j] = writeAtRuneIndex(headers[j], left, groupFrameBottom(right-left+1))
+			default:
+				headers[j] = writeAtRuneIndex(headers[j], left, box_vertical)
+				headers[j] = writeAtRuneIndex(headers[j], right, box_vertical)
+			}
+		}
+	}
+	for j := range headers {
+		headers[j] = td.margin("") + headers[j]
+	}
 	td.text += strings.Join(headers, "\n")
 	if newline {
 		td.text += "\n"
@@ -101,8 +154,17 @@ func (td *textDiagram) paddingForMessage(message sequencediagram.Message) string
 	return pad
 }
 
-// addMessage adds the message the as text to the ascii diagram
-func (td *textDiagram) addMessage(message sequencediagram.Message) {
+// margin returns the gutter with the mark, padded to the width of the gutter
+func (td *textDiagram) margin(mark string) string {
+	if td.gutter == 0 {
+		return ""
+	}
+	return mark + strings.Repeat(" ", td.gutter-utf8.RuneCountInString(mark))
+}
+
+// addMessage adds the message the as text to the ascii diagram, with the mark
+// in the gutter
+func (td *textDiagram) addMessage(message sequencediagram.Message, mark string) {
 	if t, ok := message.(sequencediagram.Title); ok {
 		td.title = t.MessageText()
 		return
@@ -117,7 +179,7 @@ func (td *textDiagram) addMessage(message sequencediagram.Message) {
 	for _, line := range strings.Split(text, "\n") {
 		//for each line, pad and draw life lines
 		line = td.fillInLifeline(pad+line, message)
-		td.text += line + "\n"
+		td.text += td.margin(mark) + line + "\n"
 	}
 }
 
@@ -133,10 +195,34 @@ func (td *textDiagram) getMessageAsText(message sequencediagram.Message) string
 		text = td.backwardMessageAsText(message)
 	case sequencediagram.Note:
 		text = noteBox(message.Msg)
+	case sequencediagram.Divider:
+		text = divider(message.Msg, td.width())
+	case sequencediagram.Loop:
+		// nested frames are indented by one rune on each side per level
+		depth := len(td.loops)
+		frame := loopFrameTop(message.Msg, td.width()-2*depth)
+		td.loops = append(td.loops, utf8.RuneCountInString(frame))
+		text = strings.Repeat(" ", depth) + frame
+	case sequencediagram.EndLoop:
+		// the bottom edge matches the top edge, which may be longer for long labels
+		length := td.width()
+		if len(td.loops) > 0 {
+			length = td.loops[len(td.loops)-1]
+			td.loops = td.loops[:len(td.loops)-1]
+		}
+		text = strings.Repeat(" ", len(td.loops)) + groupFrameBottom(length)
 	}
 	return text
 }
 
+// width returns the number of runes from the first to the last lifeline box
+func (td *textDiagram) width() int {
+	if len(td.offsets) == 0 {
+		return 0
+	}
+	return td.offsets[len(td.offsets)-1].end + 1
+}
+
 // returns the text representation of a 'to' message
 func (td *textDiagram) forwardMessageAsText(message sequencediagram.ForwardMessage) string {
 	var lines []string
@@ -204,11 +290,17 @@ func (td *textDiagram) drawFullLifeline() {
 			s += strings.Repeat(" ", of.getMiddle()-td.offsets[i-1].getMiddle()-1) + life_line
 		}
 	}
-	td.text += s + "\n"
+	td.text += td.margin("") + s + "\n"
 }
 
 // add lifeline to message text
 func (td *textDiagram) fillInLifeline(text string, message sequencediagram.Message) string {
+	switch message.(type) {
+	case sequencediagram.Divider, sequencediagram.Loop, sequencediagram.EndLoop:
+		// frame lines cross all lifelines and keep the toggle of the lines
+		// around them
+		return text
+	}
 	// toggle lifeline
 	defer func() {
 		td.lifelineToggle = !td.lifelineToggle
@@ -236,6 +328,9 @@ func (td *textDiagram) fillInLifeline(text string, message sequencediagram.Messa
 func (td *textDiagram) getStartEndIndex(message sequencediagram.Message) (int, int) {
 	var startNode, endNode int
 	switch message := message.(type) {
+	case sequencediagram.Divider, sequencediagram.Loop, sequencediagram.EndLoop:
+		// dividers and loop frames cross all lifelines
+		return -1, td.width()
 	case sequencediagram.SelfMessage:
 		startNode = message.Self.Order
 		endNode = startNode + 1
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"io"
 	"io/ioutil"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/Laugusti/sequencediagram"
@@ -16,6 +18,9 @@ func TestEncode(t *testing.T) {
 	}{
 		{readFile(t, "testdata/test1_sd.txt"), readFile(t, "testdata/test1_td.txt")},
 		{readFile(t, "testdata/test2_sd.txt"), readFile(t, "testdata/test2_td.txt")},
+		{readFile(t, "testdata/test3_sd.txt"), readFile(t, "testdata/test3_td.txt")},
+		{readFile(t, "testdata/test4_sd.txt"), readFile(t, "testdata/test4_td.txt")},
+		{readFile(t, "testdata/test5_sd.txt"), readFile(t, "testdata/test5_td.txt")},
 	}
 	for _, test := range tests {
 		got := getAsTextDiagram(t, test.text)
@@ -43,3 +48,96 @@ func getAsTextDiagram(t *testing.T, s string) string {
 	io.Copy(&b, r)
 	return b.String()
 }
+
+// frameLine matches the divider and loop frame lines of a diagram with more
+// than one participant and at most three nested loops, message boxes are
+// indented further
+var frameLine = regexp.MustCompile("^ {0,3}(" + divider_line + "|" + box_top_left + box_horizontal + " " + loop_label + "|" + box_bottom_left + box_horizontal + "+" + box_bottom_right + "$)")
+
+func TestFramesKeepLifelines(t *testing.T) {
+	tests := []struct {
+		framed, plain string
+	}{
+		{"loop l\na->b:x\nend", "a->b:x"},
+		{"a->b:x\nloop l\nb-->a:y\nloop m\na->a:z\nend\nend\na->b:w", "a->b:x\nb-->a:y\na->a:z\na->b:w"},
+		{"== one ==\na->b:x", "a->b:x"},
+		{"a->b:x\n== one ==\nb-->a:y\n== two ==\nnote right of a:n\n== a much longer label ==", "a->b:x\nb-->a:y\nnote right of a:n"},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, line := range strings.Split(getAsTextDiagram(t, test.framed), "\n") {
+			if !frameLine.MatchString(line) {
+				got = append(got, line)
+			}
+		}
+		if want := getAsTextDiagram(t, test.plain); strings.Join(got, "\n") != want {
+			t.Errorf("TestFramesKeepLifelines => input: %q\n, got without frames:\n%s\nwant:\n%s", test.framed, strings.Join(got, "\n"), want)
+		}
+	}
+}
+
+func TestEncodeMarked(t *testing.T) {
+	text := "a->b:x\nnote right of b:n"
+	sd, err := sequencediagram.ParseFromText(text)
+	if err != nil {
+		t.Fatalf("error parsing sequence diagram: %v", err)
+	}
+	var b bytes.Buffer
+	io.Copy(&b, EncodeMarked(sd, map[int]string{0: "+", 1: "-"}))
+	want := `  ┌───┐     ┌───┐
+  │ a │     │ b │
+  └───┘     └───┘
++   │  ┌───┐  │
++    ──┤ x ├─▶
++   │  └───┘  │
+-               ┌───╗
+-   │         │ │ n │
+-               └───┘
+    │         │
+  ┌───┐     ┌───┐
+  │ a │     │ b │
+  └───┘     └───┘`
+	if got := b.String(); got != want {
+		t.Errorf("TestEncodeMarked => got:\n%s\nwant:\n%s", got, want)
+	}
+	b.Reset()
+	io.Copy(&b, EncodeMarked(sd, nil))
+	if got, want := b.String(), getAsTextDiagram(t, text); got != want {
+		t.Errorf("TestEncodeMarked => without marks got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestEncodeDiff(t *testing.T) {
+	a, err := sequencediagram.ParseFromText("a->b:x\nb-->a:200 OK")
+	if err != nil {
+		t.Fatalf("error parsing sequence diagram: %v", err)
+	}
+	b, err := sequencediagram.ParseFromText("a->b:x\nb->c:y\nb-->a:500")
+	if err != nil {
+		t.Fatalf("error parsing sequence diagram: %v", err)
+	}
+	var buf bytes.Buffer
+	io.Copy(&buf, EncodeDiff(sequencediagram.Diff(a, b)))
+	want := `  ┌───┐          ┌───┐    ┌────┐
+  │ a │          │ b │    │ +c │
+  └───┘          └───┘    └────┘
+    │  ┌───┐       │         │
+     ──┤ x ├──────▶
+    │  └───┘       │         │
++                     ┌───┐
++   │              │──┤ y ├─▶│
++                     └───┘
+-   │  ┌────────┐  │         │
+-    ◀-┤ 200 OK ├--
+-   │  └────────┘  │         │
++         ┌─────┐
++   │◀----┤ 500 ├--│         │
++         └─────┘
+    │              │         │
+  ┌───┐          ┌───┐    ┌────┐
+  │ a │          │ b │    │ +c │
+  └───┘          └───┘    └────┘`
+	if got := buf.String(); got != want {
+		t.Errorf("TestEncodeDiff => got:\n%s\nwant:\n%s", got, want)
+	}
+}
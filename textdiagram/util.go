@@ -32,6 +32,19 @@ func replaceAtRuneIndex(s string, i int, new string) string {
 	return s
 }
 
+// writes new over s starting at the ith rune, padding s with spaces if it is too short
+func writeAtRuneIndex(s string, i int, new string) string {
+	runes := []rune(s)
+	for len(runes) < i {
+		runes = append(runes, ' ')
+	}
+	newRunes := []rune(new)
+	if end := i + len(newRunes); end < len(runes) {
+		return string(runes[:i]) + new + string(runes[end:])
+	}
+	return string(runes[:i]) + new
+}
+
 // finds the rune index of r (# of runes before r) in s, returns -1 if not found
 func runeIndex(s string, r rune) int {
 	var runeCount int
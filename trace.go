@@ -0,0 +1,298 @@
+package sequencediagram
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// span is a span of a trace in either OTLP or Jaeger format
+type span struct {
+	traceID, id, parentID string
+	service, name         string
+	start, end            int64
+	events                []spanEvent
+	err                   string
+}
+
+type spanEvent struct {
+	time int64
+	name string
+}
+
+// ReadTraceFile parses the OTLP JSON or Jaeger JSON trace file into a sequence
+// diagram, see ParseFromTrace
+func ReadTraceFile(filename string) (*Diagram, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseFromTrace(f)
+}
+
+// ParseFromTrace parses an OTLP JSON or Jaeger JSON trace export into a
+// sequence diagram. Each service is a node, each span is a message from the
+// service of its parent span and a dashed response with its duration when it
+// completes, and span events are notes. Messages are ordered by time.
+func ParseFromTrace(r io.Reader) (*Diagram, error) {
+	var export traceExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, fmt.Errorf("error decoding trace: %v", err)
+	}
+	spans := export.spans()
+	if len(spans) == 0 {
+		return nil, errors.New("trace has no spans")
+	}
+	return diagramFromSpans(spans), nil
+}
+
+// diagramFromSpans creates the messages of each span tree and orders them by time
+func diagramFromSpans(spans []span) *Diagram {
+	children := make(map[string][]span)
+	ids := make(map[string]bool)
+	for _, s := range spans {
+		ids[s.traceID+s.id] = true
+	}
+	var roots []span
+	for _, s := range spans {
+		// spans with a parent outside of the export are roots too
+		if s.parentID == "" || !ids[s.traceID+s.parentID] {
+			roots = append(roots, s)
+		} else {
+			children[s.traceID+s.parentID] = append(children[s.traceID+s.parentID], s)
+		}
+	}
+	byStart := func(spans []span) {
+		sort.SliceStable(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	}
+	byStart(roots)
+
+	type timedMessage struct {
+		time    int64
+		message func() Message
+	}
+	sd := &Diagram{}
+	var messages []timedMessage
+	var walk func(parent *span, s span)
+	walk = func(parent *span, s span) {
+		if parent != nil {
+			from, to, name := parent.service, s.service, s.name
+			messages = append(messages, timedMessage{s.start, func() Message {
+				return classifyMessage(sd.getOrCreateNode(from), sd.getOrCreateNode(to), simpleMessage{name}, uniDirectionalMessage{})
+			}})
+		}
+		events := append([]spanEvent(nil), s.events...)
+		sort.SliceStable(events, func(i, j int) bool { return events[i].time < events[j].time })
+		for _, event := range events {
+			service, name := s.service, event.name
+			messages = append(messages, timedMessage{event.time, func() Message {
+				return Note{sd.getOrCreateNode(service), Right, simpleMessage{name}}
+			}})
+		}
+		spanChildren := children[s.traceID+s.id]
+		byStart(spanChildren)
+		for _, child := range spanChildren {
+			walk(&s, child)
+		}
+		if parent != nil {
+			from, to := s.service, parent.service
+			response := time.Duration(s.end - s.start).String()
+			if s.err != "" {
+				response += " " + s.err
+			}
+			messages = append(messages, timedMessage{s.end, func() Message {
+				return classifyMessage(sd.getOrCreateNode(from), sd.getOrCreateNode(to), simpleMessage{response}, uniDirectionalMessage{AltArrowBody: true})
+			}})
+		}
+	}
+	for _, root := range roots {
+		walk(nil, root)
+	}
+
+	// nodes are created in time order, starting with the root services
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i].time < messages[j].time })
+	for _, root := range roots {
+		if _, ok := sd.nodes[root.service]; !ok {
+			sd.messages = append(sd.messages, Participant{Self: sd.getOrCreateNode(root.service)})
+		}
+	}
+	for _, m := range messages {
+		sd.messages = append(sd.messages, m.message())
+	}
+	return sd
+}
+
+// traceExport holds the fields of both OTLP and Jaeger trace exports
+type traceExport struct {
+	// OTLP
+	ResourceSpans []struct {
+		Resource struct {
+			Attributes []otlpAttribute `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans                  []otlpScopeSpans `json:"scopeSpans"`
+		InstrumentationLibrarySpans []otlpScopeSpans `json:"instrumentationLibrarySpans"`
+	} `json:"resourceSpans"`
+
+	// Jaeger, either a single trace or wrapped in data
+	Data []jaegerTrace `json:"data"`
+	jaegerTrace
+}
+
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+type otlpScopeSpans struct {
+	Spans []struct {
+		TraceID           string   `json:"traceId"`
+		SpanID            string   `json:"spanId"`
+		ParentSpanID      string   `json:"parentSpanId"`
+		Name              string   `json:"name"`
+		StartTimeUnixNano intValue `json:"startTimeUnixNano"`
+		EndTimeUnixNano   intValue `json:"endTimeUnixNano"`
+		Events            []struct {
+			TimeUnixNano intValue `json:"timeUnixNano"`
+			Name         string   `json:"name"`
+		} `json:"events"`
+		Status struct {
+			Code    json.RawMessage `json:"code"`
+			Message string          `json:"message"`
+		} `json:"status"`
+	} `json:"spans"`
+}
+
+type jaegerTrace struct {
+	TraceID string `json:"traceID"`
+	Spans   []struct {
+		TraceID       string `json:"traceID"`
+		SpanID        string `json:"spanID"`
+		OperationName string `json:"operationName"`
+		References    []struct {
+			RefType string `json:"refType"`
+			TraceID string `json:"traceID"`
+			SpanID  string `json:"spanID"`
+		} `json:"references"`
+		StartTime intValue    `json:"startTime"`
+		Duration  intValue    `json:"duration"`
+		ProcessID string      `json:"processID"`
+		Tags      []jaegerTag `json:"tags"`
+		Logs      []struct {
+			Timestamp intValue    `json:"timestamp"`
+			Fields    []jaegerTag `json:"fields"`
+		} `json:"logs"`
+	} `json:"spans"`
+	Processes map[string]struct {
+		ServiceName string `json:"serviceName"`
+	} `json:"processes"`
+}
+
+type jaegerTag struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// intValue is an integer that is encoded as either a JSON number or string
+type intValue int64
+
+func (v *intValue) UnmarshalJSON(b []byte) error {
+	i, err := strconv.ParseInt(strings.Trim(string(b), `"`), 10, 64)
+	if err != nil {
+		return err
+	}
+	*v = intValue(i)
+	return nil
+}
+
+// spans returns the spans of the export with times in nanoseconds
+func (e traceExport) spans() []span {
+	var spans []span
+	for _, rs := range e.ResourceSpans {
+		service := "unknown"
+		for _, attr := range rs.Resource.Attributes {
+			if attr.Key == "service.name" {
+				service = attr.Value.StringValue
+			}
+		}
+		for _, ss := range append(rs.ScopeSpans, rs.InstrumentationLibrarySpans...) {
+			for _, s := range ss.Spans {
+				sp := span{
+					traceID:  s.TraceID,
+					id:       s.SpanID,
+					parentID: s.ParentSpanID,
+					service:  service,
+					name:     s.Name,
+					start:    int64(s.StartTimeUnixNano),
+					end:      int64(s.EndTimeUnixNano),
+				}
+				for _, event := range s.Events {
+					sp.events = append(sp.events, spanEvent{int64(event.TimeUnixNano), event.Name})
+				}
+				// status code is 2 or "STATUS_CODE_ERROR" for errors
+				if code := strings.Trim(string(s.Status.Code), `"`); code == "2" || code == "STATUS_CODE_ERROR" {
+					sp.err = "error"
+					if s.Status.Message != "" {
+						sp.err += ": " + s.Status.Message
+					}
+				}
+				spans = append(spans, sp)
+			}
+		}
+	}
+
+	for _, trace := range append(e.Data, e.jaegerTrace) {
+		for _, s := range trace.Spans {
+			sp := span{
+				traceID: s.TraceID,
+				id:      s.SpanID,
+				service: trace.Processes[s.ProcessID].ServiceName,
+				name:    s.OperationName,
+				// jaeger uses microseconds
+				start: int64(s.StartTime) * 1000,
+				end:   int64(s.StartTime+s.Duration) * 1000,
+			}
+			if sp.service == "" {
+				sp.service = "unknown"
+			}
+			for _, ref := range s.References {
+				if ref.RefType == "CHILD_OF" && ref.TraceID == s.TraceID {
+					sp.parentID = ref.SpanID
+				}
+			}
+			for _, log := range s.Logs {
+				sp.events = append(sp.events, spanEvent{int64(log.Timestamp) * 1000, jaegerEventName(log.Fields)})
+			}
+			for _, tag := range s.Tags {
+				if tag.Key == "error" && fmt.Sprint(tag.Value) == "true" {
+					sp.err = "error"
+				}
+			}
+			spans = append(spans, sp)
+		}
+	}
+	return spans
+}
+
+// jaegerEventName returns the event or message field of a log, or else the first field
+func jaegerEventName(fields []jaegerTag) string {
+	for _, key := range []string{"event", "message"} {
+		for _, field := range fields {
+			if field.Key == key {
+				return fmt.Sprint(field.Value)
+			}
+		}
+	}
+	if len(fields) > 0 {
+		return fmt.Sprintf("%s=%v", fields[0].Key, fields[0].Value)
+	}
+	return "log"
+}
@@ -0,0 +1,55 @@
+package sequencediagram
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadTraceFile(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{"testdata/otlp.json", `participant frontend
+frontend->cart:GetCart
+note right of cart:cache miss
+cart-->frontend:15ms
+frontend->payment:Charge
+payment->payment:INSERT
+payment-->payment:2.5ms
+payment-->frontend:50ms error: card declined`},
+		{"testdata/jaeger.json", `participant frontend
+frontend->cart:GetCart
+note right of cart:cache miss
+cart-->frontend:15ms
+frontend->payment:Charge
+payment->payment:INSERT
+payment-->payment:2.5ms
+payment-->frontend:50ms error`},
+	}
+	for _, test := range tests {
+		sd, err := ReadTraceFile(test.filename)
+		if err != nil {
+			t.Errorf("TestReadTraceFile => %s: got error: %v", test.filename, err)
+			continue
+		}
+		if sd.String() != test.want {
+			t.Errorf("TestReadTraceFile => %s: got:\n%s\nwant:\n%s", test.filename, sd, test.want)
+		}
+	}
+}
+
+func TestParseFromTraceErrors(t *testing.T) {
+	tests := []struct {
+		text string
+	}{
+		{"not json"},
+		{"{}"},
+		{`{"resourceSpans": [{"scopeSpans": [{"spans": [{"startTimeUnixNano": "x"}]}]}]}`},
+	}
+	for _, test := range tests {
+		if _, err := ParseFromTrace(strings.NewReader(test.text)); err == nil {
+			t.Errorf("TestParseFromTraceErrors => input: %q, expected error", test.text)
+		}
+	}
+}